@@ -6,6 +6,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"time"
 
 	"github.com/majiru/ffs"
 	"github.com/majiru/ffs/fs/diskfs"
@@ -14,12 +15,22 @@ import (
 	"github.com/majiru/ffs/fs/mediafs"
 	"github.com/majiru/ffs/fs/pastefs"
 	"github.com/majiru/ffs/fs/jukeboxfs"
+	"github.com/majiru/ffs/pkg/fsutil"
 )
 
 type FSConf struct {
 	Name string
 	SubDom string
 	Args []string
+	//Memory limits for in memory filesystems, zero means unlimited.
+	//Evict drops the least recently used files instead of failing writes.
+	MaxBytes int64
+	MaxFileBytes int64
+	Evict bool
+	//Seconds between rescans of jukeboxfs, zero never rescans on a timer.
+	//Watch rescans it when its directory changes.
+	Rescan int64
+	Watch bool
 	fs ffs.Fs
 }
 
@@ -32,7 +43,7 @@ type Config struct {
 }
 
 func genDefaultConf(f io.WriteSeeker) error {
-	webfs := &FSConf{"diskfs", "www", []string{"./www"}, nil}
+	webfs := &FSConf{"diskfs", "www", []string{"./www"}, 0, 0, false, 0, false, nil}
 	conf := Config{
 		false,
 		"",
@@ -40,7 +51,7 @@ func genDefaultConf(f io.WriteSeeker) error {
 		[]string{"localhost", "example.com"},
 		[]*FSConf{
 			webfs,
-			&FSConf{"pastefs", "paste", []string{}, nil},
+			&FSConf{"pastefs", "paste", []string{}, 0, 0, false, 0, false, nil},
 		},
 	}
 
@@ -63,7 +74,13 @@ func parseFSConf(c *FSConf) error {
 	case "diskfs":
 		c.fs = &diskfs.Diskfs{c.Args[0]}
 	case "pastefs":
-		c.fs = pastefs.NewPastefs()
+		p := pastefs.NewPastefs()
+		if c.MaxBytes > 0 || c.MaxFileBytes > 0 {
+			q := fsutil.NewQuota(c.MaxBytes, c.MaxFileBytes)
+			q.Evict = c.Evict
+			p.SetQuota(q)
+		}
+		c.fs = p
 	case "mediafs":
 		if len(c.Args) > 0 {
 			f, err := os.Open(c.Args[1])
@@ -80,7 +97,19 @@ func parseFSConf(c *FSConf) error {
 		if len(c.Args) != 1 {
 			return errors.New("parseFSConf: Not enough/Too many args to jukeboxfs")
 		}
-		c.fs, err = jukeboxfs.NewJukefs(c.Args[0])
+		j, err := jukeboxfs.NewJukefs(c.Args[0])
+		if err != nil {
+			return err
+		}
+		if c.Rescan > 0 {
+			j.RescanEvery(time.Duration(c.Rescan) * time.Second)
+		}
+		if c.Watch {
+			if err = j.Watch(); err != nil {
+				return err
+			}
+		}
+		c.fs = j
 	default:
 		return errors.New("parseFSConf: Unknown fs")
 	}
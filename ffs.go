@@ -2,10 +2,23 @@
 package ffs
 
 import (
+	"errors"
 	"io"
 	"os"
 )
 
+//ErrNoSpace is returned by a Writer when a write would exceed
+//the space allotted to the file or to the filesystem holding it.
+var ErrNoSpace = errors.New("no space left on device")
+
+//UserKey is the context key under which servers pass files
+//the name of the user they were opened for.
+type UserKey struct{}
+
+//BaseURLKey is the context key under which files opened over HTTP
+//find the scheme and host the request was made to, as in https://example.com.
+type BaseURLKey struct{}
+
 //File represenets a read only file.
 //os.File satisfies this interface.
 type File interface {
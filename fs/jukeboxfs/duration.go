@@ -0,0 +1,244 @@
+package jukeboxfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+)
+
+//duration returns the playing time of r, size bytes long and in format,
+//or 0 if it can not tell.
+func duration(r io.ReaderAt, size int64, format string) time.Duration {
+	var secs float64
+	switch format {
+	case formatFLAC:
+		secs = flacDuration(r)
+	case formatWAV:
+		secs = wavDuration(r)
+	case formatAIFF:
+		secs = aiffDuration(r)
+	case formatMP3:
+		secs = mp3Duration(r, size)
+	case formatM4A:
+		secs = m4aDuration(r, 0, size)
+	case formatOGG, formatOpus:
+		secs = oggDuration(r, size)
+	}
+	if secs <= 0 || math.IsInf(secs, 0) || math.IsNaN(secs) {
+		return 0
+	}
+	return time.Duration(secs * float64(time.Second))
+}
+
+//flacDuration reads the sample rate and count from STREAMINFO,
+//which is always the first metadata block.
+func flacDuration(r io.ReaderAt) float64 {
+	b := make([]byte, 8+18)
+	if _, err := r.ReadAt(b, 0); err != nil || string(b[:4]) != "fLaC" || b[4]&0x7f != 0 {
+		return 0
+	}
+	d := b[8:]
+	rate := uint32(d[10])<<12 | uint32(d[11])<<4 | uint32(d[12])>>4
+	samples := uint64(d[13]&0x0f)<<32 | uint64(binary.BigEndian.Uint32(d[14:]))
+	if rate == 0 {
+		return 0
+	}
+	return float64(samples) / float64(rate)
+}
+
+func wavDuration(r io.ReaderAt) float64 {
+	fmtc, err := chunk(r, true, "fmt ")
+	if err != nil {
+		return 0
+	}
+	b := make([]byte, 12)
+	if _, err = fmtc.ReadAt(b, 0); err != nil {
+		return 0
+	}
+	rate := binary.LittleEndian.Uint32(b[8:])
+	data, err := chunk(r, true, "data")
+	if err != nil || rate == 0 {
+		return 0
+	}
+	return float64(data.Size()) / float64(rate)
+}
+
+//aiffDuration reads the frame count and rate from COMM,
+//the rate is an 80 bit float.
+func aiffDuration(r io.ReaderAt) float64 {
+	comm, err := chunk(r, false, "COMM")
+	if err != nil {
+		return 0
+	}
+	b := make([]byte, 18)
+	if _, err = comm.ReadAt(b, 0); err != nil {
+		return 0
+	}
+	frames := binary.BigEndian.Uint32(b[2:])
+	exp := int(binary.BigEndian.Uint16(b[8:]) & 0x7fff)
+	rate := math.Ldexp(float64(binary.BigEndian.Uint64(b[10:])), exp-16383-63)
+	if rate == 0 {
+		return 0
+	}
+	return float64(frames) / rate
+}
+
+//Bitrates in kbit/s of layer III, by MPEG-1 and MPEG-2 or 2.5.
+var mp3Bitrates = [2][16]int{
+	{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+	{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+}
+
+//Sample rates by version, as numbered in the header.
+var mp3Rates = map[byte][3]int{
+	3: {44100, 48000, 32000},
+	2: {22050, 24000, 16000},
+	0: {11025, 12000, 8000},
+}
+
+//mp3Duration counts the frames of a Xing or VBRI header,
+//otherwise the bitrate of the first frame is taken to be constant.
+func mp3Duration(r io.ReaderAt, size int64) float64 {
+	var start int64
+	b := make([]byte, 10)
+	if _, err := r.ReadAt(b, 0); err != nil {
+		return 0
+	}
+	if string(b[:3]) == "ID3" {
+		start = 10 + (int64(b[6])<<21 | int64(b[7])<<14 | int64(b[8])<<7 | int64(b[9]))
+	}
+	//Look for the first frame a little past the tag
+	buf := make([]byte, 4096)
+	n, _ := r.ReadAt(buf, start)
+	i := 0
+	for ; i+4 <= n; i++ {
+		if buf[i] == 0xff && buf[i+1]&0xe6 == 0xe2 && buf[i+2]&0xf0 != 0xf0 && buf[i+2]&0x0c != 0x0c {
+			break
+		}
+	}
+	if i+4 > n {
+		return 0
+	}
+	h := buf[i:]
+	version := h[1] >> 3 & 3
+	rates, ok := mp3Rates[version]
+	if !ok {
+		return 0
+	}
+	rate := rates[h[2]>>2&3]
+	mpeg1, mono := version == 3, h[3]>>6 == 3
+	samples := 576
+	v := 1
+	if mpeg1 {
+		samples, v = 1152, 0
+	}
+	bitrate := mp3Bitrates[v][h[2]>>4]
+	//The Xing header follows the side information
+	side := 17
+	switch {
+	case mpeg1 && !mono:
+		side = 32
+	case !mpeg1 && mono:
+		side = 9
+	}
+	frames := 0
+	x := make([]byte, 12)
+	if _, err := r.ReadAt(x, start+int64(i)+4+int64(side)); err == nil && (string(x[:4]) == "Xing" || string(x[:4]) == "Info") && x[7]&1 != 0 {
+		frames = int(binary.BigEndian.Uint32(x[8:]))
+	} else if _, err := r.ReadAt(x, start+int64(i)+36); err == nil && string(x[:4]) == "VBRI" {
+		vb := make([]byte, 4)
+		if _, err := r.ReadAt(vb, start+int64(i)+36+14); err == nil {
+			frames = int(binary.BigEndian.Uint32(vb))
+		}
+	}
+	switch {
+	case frames > 0:
+		return float64(frames*samples) / float64(rate)
+	case bitrate > 0:
+		return float64(size-start-int64(i)) * 8 / float64(bitrate*1000)
+	}
+	return 0
+}
+
+//m4aDuration looks for the movie header inside the moov atom
+//between off and end.
+func m4aDuration(r io.ReaderAt, off, end int64) float64 {
+	b := make([]byte, 16)
+	for off+8 <= end {
+		if _, err := r.ReadAt(b[:8], off); err != nil {
+			return 0
+		}
+		size, head := int64(binary.BigEndian.Uint32(b)), int64(8)
+		typ := string(b[4:8])
+		switch size {
+		case 0:
+			size = end - off
+		case 1:
+			if _, err := r.ReadAt(b[:8], off+8); err != nil {
+				return 0
+			}
+			size, head = int64(binary.BigEndian.Uint64(b)), 16
+		}
+		if size < head {
+			return 0
+		}
+		switch typ {
+		case "moov":
+			return m4aDuration(r, off+head, off+size)
+		case "mvhd":
+			hdr := make([]byte, 32)
+			if _, err := r.ReadAt(hdr, off+head); err != nil {
+				return 0
+			}
+			var scale uint32
+			var length uint64
+			if hdr[0] == 1 {
+				scale, length = binary.BigEndian.Uint32(hdr[20:]), binary.BigEndian.Uint64(hdr[24:])
+			} else {
+				scale, length = binary.BigEndian.Uint32(hdr[12:]), uint64(binary.BigEndian.Uint32(hdr[16:]))
+			}
+			if scale == 0 {
+				return 0
+			}
+			return float64(length) / float64(scale)
+		}
+		off += size
+	}
+	return 0
+}
+
+//oggDuration divides the granule position of the last page by the
+//sample rate from the first, Opus always counts at 48kHz after its pre-skip.
+func oggDuration(r io.ReaderAt, size int64) float64 {
+	first := make([]byte, 27+255+19)
+	n, _ := r.ReadAt(first, 0)
+	if n < 28 || string(first[:4]) != "OggS" || 27+int(first[26]) > n {
+		return 0
+	}
+	packet := first[27+int(first[26]):n]
+	var rate, skip float64
+	switch {
+	case len(packet) >= 12 && string(packet[:8]) == "OpusHead":
+		rate, skip = 48000, float64(binary.LittleEndian.Uint16(packet[10:]))
+	case len(packet) >= 16 && string(packet[1:7]) == "vorbis":
+		rate = float64(binary.LittleEndian.Uint32(packet[12:]))
+	default:
+		return 0
+	}
+	tail := int64(65536)
+	if tail > size {
+		tail = size
+	}
+	last := make([]byte, tail)
+	if _, err := r.ReadAt(last, size-tail); err != nil && err != io.EOF {
+		return 0
+	}
+	i := bytes.LastIndex(last, []byte("OggS"))
+	if i < 0 || i+14 > len(last) || rate == 0 {
+		return 0
+	}
+	granule := float64(binary.LittleEndian.Uint64(last[i+6:]))
+	return (granule - skip) / rate
+}
@@ -0,0 +1,193 @@
+package jukeboxfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dhowden/tag"
+)
+
+//Audio formats, named by their usual extension.
+const (
+	formatMP3  = "mp3"
+	formatFLAC = "flac"
+	formatOGG  = "ogg"
+	formatOpus = "opus"
+	formatM4A  = "m4a"
+	formatAAC  = "aac"
+	formatWAV  = "wav"
+	formatAIFF = "aiff"
+)
+
+//extensions maps the extensions of audio files to their format.
+var extensions = map[string]string{
+	".mp3":  formatMP3,
+	".flac": formatFLAC,
+	".ogg":  formatOGG,
+	".oga":  formatOGG,
+	".opus": formatOpus,
+	".m4a":  formatM4A,
+	".mp4":  formatM4A,
+	".aac":  formatAAC,
+	".wav":  formatWAV,
+	".aif":  formatAIFF,
+	".aiff": formatAIFF,
+	".aifc": formatAIFF,
+}
+
+//tagged is the metadata of a file with what was read from the audio itself.
+type tagged struct {
+	tag.Metadata
+	format string
+	length time.Duration
+	//size and mtime are those of the file
+	size  int64
+	mtime time.Time
+}
+
+//extFormat returns the format named by the extension of name, if any.
+func extFormat(name string) string {
+	return extensions[strings.ToLower(filepath.Ext(name))]
+}
+
+//sniff returns the format of the data starting with b,
+//or "" if it is not audio. ID3 tagged data is taken to be mp3
+//unless hint, the format from the extension, says aac.
+func sniff(b []byte, hint string) string {
+	switch {
+	case len(b) < 12:
+	case bytes.HasPrefix(b, []byte("ID3")):
+		if hint == formatAAC {
+			return formatAAC
+		}
+		return formatMP3
+	case bytes.HasPrefix(b, []byte("fLaC")):
+		return formatFLAC
+	case bytes.HasPrefix(b, []byte("OggS")):
+		if len(b) >= 36 && string(b[28:36]) == "OpusHead" {
+			return formatOpus
+		}
+		return formatOGG
+	case string(b[4:8]) == "ftyp":
+		return formatM4A
+	case string(b[0:4]) == "RIFF" && string(b[8:12]) == "WAVE":
+		return formatWAV
+	case string(b[0:4]) == "FORM" && (string(b[8:12]) == "AIFF" || string(b[8:12]) == "AIFC"):
+		return formatAIFF
+	//Both start with a frame sync, ADTS has a layer of 0
+	case b[0] == 0xFF && b[1]&0xF6 == 0xF0:
+		return formatAAC
+	case b[0] == 0xFF && b[1]&0xE0 == 0xE0 && b[1]&0x06 != 0:
+		return formatMP3
+	}
+	return ""
+}
+
+//detect returns the format of r, whose name has the format hint.
+func detect(r io.ReadSeeker, hint string) (string, error) {
+	b := make([]byte, 36)
+	n, err := io.ReadFull(r, b)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return sniff(b[:n], hint), nil
+}
+
+//readTags reads the tags of r in format.
+//WAV and AIFF keep theirs in an ID3 chunk.
+func readTags(r io.ReadSeeker, format string) (tag.Metadata, error) {
+	switch format {
+	case formatWAV, formatAIFF:
+		ra, ok := r.(io.ReaderAt)
+		if !ok {
+			return nil, tag.ErrNoTagsFound
+		}
+		sr, err := chunk(ra, format == formatWAV, "id3 ")
+		if err != nil {
+			return nil, tag.ErrNoTagsFound
+		}
+		return tag.ReadID3v2Tags(sr)
+	}
+	return tag.ReadFrom(r)
+}
+
+//chunk finds the chunk id, matched regardless of case, of a RIFF
+//or, if not little, IFF file.
+func chunk(ra io.ReaderAt, little bool, id string) (*io.SectionReader, error) {
+	var order binary.ByteOrder = binary.BigEndian
+	if little {
+		order = binary.LittleEndian
+	}
+	b := make([]byte, 8)
+	for off := int64(12); ; {
+		if _, err := ra.ReadAt(b, off); err != nil {
+			return nil, err
+		}
+		size := int64(order.Uint32(b[4:]))
+		if strings.EqualFold(string(b[:4]), id) {
+			return io.NewSectionReader(ra, off+8, size), nil
+		}
+		//Chunks are padded to an even size
+		off += 8 + size + size&1
+	}
+}
+
+//numbered matches names starting with a track number.
+var numbered = regexp.MustCompile(`^(\d{1,3})[ .\-_]+(.*)$`)
+
+//fileMeta is the metadata of an untagged file, guessed from its path:
+//Artist/Album/NN - Title, or NN - Artist - Title in the file name.
+type fileMeta struct {
+	title, album, artist string
+	track                int
+}
+
+//fromPath guesses the metadata of fpath, found under root.
+func fromPath(root, fpath string) tag.Metadata {
+	m := fileMeta{}
+	name := strings.TrimSuffix(filepath.Base(fpath), filepath.Ext(fpath))
+	if s := numbered.FindStringSubmatch(name); s != nil {
+		m.track, _ = strconv.Atoi(s[1])
+		name = s[2]
+	}
+	if i := strings.Index(name, " - "); i > 0 {
+		m.artist = strings.TrimSpace(name[:i])
+		name = name[i+3:]
+	}
+	m.title = strings.TrimSpace(name)
+	rel, err := filepath.Rel(root, filepath.Dir(fpath))
+	if err != nil || rel == "." {
+		return m
+	}
+	dirs := strings.Split(filepath.ToSlash(rel), "/")
+	m.album = dirs[len(dirs)-1]
+	if m.artist == "" && len(dirs) > 1 {
+		m.artist = dirs[len(dirs)-2]
+	}
+	return m
+}
+
+func (m fileMeta) Format() tag.Format          { return tag.UnknownFormat }
+func (m fileMeta) FileType() tag.FileType      { return tag.UnknownFileType }
+func (m fileMeta) Title() string               { return m.title }
+func (m fileMeta) Album() string               { return m.album }
+func (m fileMeta) Artist() string              { return m.artist }
+func (m fileMeta) AlbumArtist() string         { return "" }
+func (m fileMeta) Composer() string            { return "" }
+func (m fileMeta) Year() int                   { return 0 }
+func (m fileMeta) Genre() string               { return "" }
+func (m fileMeta) Track() (int, int)           { return m.track, 0 }
+func (m fileMeta) Disc() (int, int)            { return 0, 0 }
+func (m fileMeta) Picture() *tag.Picture       { return nil }
+func (m fileMeta) Lyrics() string              { return "" }
+func (m fileMeta) Comment() string             { return "" }
+func (m fileMeta) Raw() map[string]interface{} { return nil }
@@ -0,0 +1,121 @@
+package jukeboxfs
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+//id3 encodes an ID3v2.3 tag with the text frames given as id, value pairs.
+func id3(frames ...string) []byte {
+	var body []byte
+	for i := 0; i+1 < len(frames); i += 2 {
+		h := make([]byte, 10)
+		copy(h, frames[i])
+		binary.BigEndian.PutUint32(h[4:], uint32(len(frames[i+1])+1))
+		body = append(append(append(body, h...), 0), frames[i+1]...)
+	}
+	n := len(body)
+	head := []byte{'I', 'D', '3', 3, 0, 0, byte(n >> 21 & 0x7f), byte(n >> 14 & 0x7f), byte(n >> 7 & 0x7f), byte(n & 0x7f)}
+	return append(head, body...)
+}
+
+//chunked encodes a RIFF or IFF file of form with the chunks given as id, data pairs.
+func chunked(riff bool, form string, chunks ...[]byte) []byte {
+	var order binary.ByteOrder = binary.BigEndian
+	magic := "FORM"
+	if riff {
+		order, magic = binary.LittleEndian, "RIFF"
+	}
+	body := []byte(form)
+	for i := 0; i+1 < len(chunks); i += 2 {
+		h := make([]byte, 8)
+		copy(h, chunks[i])
+		order.PutUint32(h[4:], uint32(len(chunks[i+1])))
+		body = append(append(body, h...), chunks[i+1]...)
+		if len(chunks[i+1])%2 == 1 {
+			body = append(body, 0)
+		}
+	}
+	h := make([]byte, 8)
+	copy(h, magic)
+	order.PutUint32(h[4:], uint32(len(body)))
+	return append(h, body...)
+}
+
+func TestSniff(t *testing.T) {
+	opus := append([]byte("OggS"), make([]byte, 24)...)
+	tests := []struct {
+		b      []byte
+		hint   string
+		expect string
+	}{
+		{id3("TIT2", "x"), formatMP3, formatMP3},
+		{id3("TIT2", "x"), formatAAC, formatAAC},
+		{append([]byte("fLaC"), make([]byte, 8)...), "", formatFLAC},
+		{append([]byte("OggS"), make([]byte, 32)...), "", formatOGG},
+		{append(opus, "OpusHead"...), "", formatOpus},
+		{append([]byte{0, 0, 0, 0x20}, "ftypM4A     "...), "", formatM4A},
+		{chunked(true, "WAVE"), "", formatWAV},
+		{chunked(false, "AIFF"), "", formatAIFF},
+		{append([]byte{0xff, 0xf1}, make([]byte, 10)...), "", formatAAC},
+		{append([]byte{0xff, 0xfb}, make([]byte, 10)...), "", formatMP3},
+		{[]byte("just some text"), formatMP3, ""},
+	}
+	for _, test := range tests {
+		if s := sniff(test.b, test.hint); s != test.expect {
+			t.Fatalf("expected %q got %q for % x", test.expect, s, test.b[:12])
+		}
+	}
+}
+
+func TestFromPath(t *testing.T) {
+	tests := []struct {
+		fpath                string
+		title, album, artist string
+		track                int
+	}{
+		{"/music/Band/Record/03 - Song.wav", "Song", "Record", "Band", 3},
+		{"/music/Record/07. Singer - Song.aiff", "Song", "Record", "Singer", 7},
+		{"/music/Song.opus", "Song", "", "", 0},
+	}
+	for _, test := range tests {
+		m := fromPath("/music", test.fpath)
+		n, _ := m.Track()
+		if m.Title() != test.title || m.Album() != test.album || m.Artist() != test.artist || n != test.track {
+			t.Fatalf("%s: got %q %q %q %d", test.fpath, m.Title(), m.Album(), m.Artist(), n)
+		}
+	}
+}
+
+func TestFormats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jukeboxfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string][]byte{
+		"a.mp3":             id3("TIT2", "Tagged", "TALB", "Album"),
+		"b.wav":             chunked(true, "WAVE", []byte("fmt "), make([]byte, 16), []byte("id3 "), id3("TIT2", "Wave", "TALB", "Album")),
+		"c.aiff":            chunked(false, "AIFF", []byte("COMM"), make([]byte, 18), []byte("ID3 "), id3("TIT2", "Aiff", "TALB", "Album")),
+		"02 - Untagged.wav": chunked(true, "WAVE", []byte("fmt "), make([]byte, 16)),
+		"fake.mp3":          []byte("not audio at all"),
+		"foo.notmp3":        id3("TIT2", "Skipped"),
+	}
+	for name, b := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fs, err := NewJukefs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, fs, "/", "albums", "artists", "genres", "years", "files", "playlists", "ctl")
+	expect(t, fs, "/albums", "Album", "Unknown")
+	expect(t, fs, "/albums/Album", "Tagged.mp3", "Wave.wav", "Aiff.aiff", "playlist.m3u8", "playlist.pls")
+	expect(t, fs, "/albums/Unknown", "02 - Untagged.wav", "playlist.m3u8", "playlist.pls")
+	expect(t, fs, "/files", "02 - Untagged.wav", "a.mp3", "b.wav", "c.aiff")
+}
@@ -1,38 +1,63 @@
 package jukeboxfs
 
 import (
+	"fmt"
 	"html/template"
-	"io"
-	"io/ioutil"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/dhowden/tag"
 	"github.com/majiru/ffs"
+	"github.com/majiru/ffs/pkg/ctl"
 	"github.com/majiru/ffs/pkg/fsutil"
 )
 
+//Jukefs serves the audio files under a directory by their tags,
+//in albums, artists, genres and years views, next to a files view
+//of the directory itself. Each track file opens the file it was read from.
+//The directory is rescanned by writing rescan to /ctl, on a timer
+//or, where supported, when it changes.
 type Jukefs struct {
 	*sync.RWMutex
 	path string
 	root *fsutil.Dir
 	info map[string]tag.Metadata
+	//stamps holds the size and modification time of every file with
+	//an audio extension when it was last read, audio or not
+	stamps map[string]stamp
+	//playlists is the directory on disk holding the user playlists
+	//of /playlists, it is made by the first newplaylist
+	playlists string
+	ctl       *ctl.Ctl
+	//scan is held by the running rescan, last describes the one before
+	scan     sync.Mutex
+	last     string
+	watching bool
+	stop     chan struct{}
+	closed   sync.Once
 }
 
 func NewJukefs(root string) (*Jukefs, error) {
 	fs := &Jukefs{
-		&sync.RWMutex{},
-		root,
-		fsutil.CreateDir("/"),
-		make(map[string]tag.Metadata),
+		RWMutex: &sync.RWMutex{},
+		path:    root,
+		info:    make(map[string]tag.Metadata),
+		stamps:  make(map[string]stamp),
+		stop:    make(chan struct{}),
 	}
-	if err := fs.updateMetadata(); err != nil {
+	fs.playlists = filepath.Join(root, ".playlists")
+	fs.ctl = fs.newCtl()
+	fs.root = fs.tree(fs.info)
+	if err := fs.Rescan(); err != nil {
 		return nil, err
 	}
-	fs.updateTree()
 	return fs, nil
 }
 
@@ -41,69 +66,399 @@ type msg struct {
 	s string
 }
 
-func (fs *Jukefs) updateMetadata() error {
-	fs.Lock()
+//readChanged reads the tags of the audio files under the root,
+//reusing those of old for files whose stamp has not changed.
+//Files are picked by extension and checked by their first bytes,
+//untagged ones get what their path tells of them.
+func (fs *Jukefs) readChanged(old map[string]tag.Metadata, oldstamps map[string]stamp) (map[string]tag.Metadata, map[string]stamp, error) {
+	info := make(map[string]tag.Metadata)
+	stamps := make(map[string]stamp)
 	wg := &sync.WaitGroup{}
 	parseout := make(chan msg, 4)
 	walkout := make(chan msg, 4)
+	done := make(chan struct{})
 	go func() {
 		for i := range parseout {
-			fs.info[i.s] = i.t
+			info[i.s] = i.t
 		}
+		close(done)
 	}()
 	wg.Add(4)
 	for i := 0; i < 4; i++ {
-		go func(){
+		go func() {
 			for j := range walkout {
-				f, err := os.Open(j.s)
+				m, err := fs.readMetadata(j.s)
 				if err != nil {
 					log.Println(err)
 					continue
 				}
-				m, err := tag.ReadFrom(f)
-				f.Close()
-				if err != nil {
-					log.Println(err)
-					continue
+				if m != nil {
+					j.t = m
+					parseout <- j
 				}
-				j.t = m
-				parseout <- j
 			}
 			wg.Done()
 		}()
 	}
 	err := filepath.Walk(fs.path, func(path string, info os.FileInfo, err error) error {
-		if !info.IsDir() && (strings.HasSuffix(info.Name(), "mp3") || strings.HasSuffix(info.Name(), "flac")) {
-			walkout <- msg{s: path}
+		if err != nil {
+			log.Println(err)
+			return nil
+		}
+		if info.IsDir() && path == fs.playlists {
+			return filepath.SkipDir
 		}
+		if info.IsDir() || extFormat(info.Name()) == "" {
+			return nil
+		}
+		st := stamp{info.Size(), info.ModTime()}
+		stamps[path] = st
+		if prev, ok := oldstamps[path]; ok && prev.same(st) {
+			if m, ok := old[path]; ok {
+				parseout <- msg{m, path}
+			}
+			return nil
+		}
+		walkout <- msg{s: path}
 		return nil
 	})
 	close(walkout)
 	wg.Wait()
 	close(parseout)
-	fs.Unlock()
-	return err
+	<-done
+	return info, stamps, err
+}
+
+//readMetadata returns the metadata of the file at fpath,
+//or nil if it is not audio.
+func (fs *Jukefs) readMetadata(fpath string) (tag.Metadata, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	format, err := detect(f, extFormat(fpath))
+	if err != nil || format == "" {
+		return nil, err
+	}
+	m, err := readTags(f, format)
+	if err != nil {
+		if err != tag.ErrNoTagsFound {
+			log.Println(fpath+":", err)
+		}
+		m = fromPath(fs.path, fpath)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return tagged{m, format, duration(f, fi.Size(), format), fi.Size(), fi.ModTime()}, nil
+}
+
+//unknown names the directory of tracks missing a tag.
+const unknown = "Unknown"
+
+//track is a tagged file, as it is placed in the views.
+type track struct {
+	path string
+	m    tag.Metadata
+}
+
+//clean makes s usable as a file name.
+func clean(s string) string {
+	s = strings.TrimSpace(strings.Replace(s, "/", "", -1))
+	if s == "" {
+		return unknown
+	}
+	return s
+}
+
+func (t track) album() string {
+	return clean(t.m.Album())
+}
+
+//artist prefers the album artist, so compilations stay together.
+func (t track) artist() string {
+	if a := t.m.AlbumArtist(); strings.TrimSpace(a) != "" {
+		return clean(a)
+	}
+	return clean(t.m.Artist())
+}
+
+func (t track) year() string {
+	if y := t.m.Year(); y > 0 {
+		return strconv.Itoa(y)
+	}
+	return unknown
+}
+
+//name is the title prefixed by the track number, and the disc
+//number for albums of several discs.
+//Untitled tracks are named after their file.
+func (t track) name() string {
+	title := t.m.Title()
+	if strings.TrimSpace(title) == "" {
+		title = strings.TrimSuffix(filepath.Base(t.path), filepath.Ext(t.path))
+	}
+	title = clean(title)
+	n, _ := t.m.Track()
+	disc, discs := t.m.Disc()
+	switch {
+	case n > 0 && (disc > 1 || discs > 1):
+		return fmt.Sprintf("%d-%02d - %s", disc, n, title)
+	case n > 0:
+		return fmt.Sprintf("%02d - %s", n, title)
+	}
+	return title
+}
+
+//less orders tracks by album, disc and track number.
+func (t track) less(o track) bool {
+	if a, b := t.album(), o.album(); a != b {
+		return a < b
+	}
+	d1, _ := t.m.Disc()
+	d2, _ := o.m.Disc()
+	if d1 != d2 {
+		return d1 < d2
+	}
+	n1, _ := t.m.Track()
+	n2, _ := o.m.Track()
+	if n1 != n2 {
+		return n1 < n2
+	}
+	return t.path < o.path
+}
+
+//places lists where t is in the album views,
+//each as its view and the directories leading to its album.
+func (t track) places() [][]string {
+	album := t.album()
+	return [][]string{
+		{"albums", album},
+		{"artists", t.artist(), album},
+		{"genres", clean(t.m.Genre()), album},
+		{"years", t.year(), album},
+	}
+}
+
+//hasPlaylists tells whether the album directories of view,
+//and those leading to them, get playlists.
+func hasPlaylists(view string) bool {
+	return view != "years"
+}
+
+//uniqueName returns name, or if it is taken in d
+//the first free one numbered before its extension.
+func uniqueName(d *fsutil.Dir, name string) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 2; ; i++ {
+		if _, err := d.Find(name); err != nil {
+			return name
+		}
+		name = base + strconv.Itoa(i) + ext
+	}
+}
+
+//album makes the directory of an album at fpath, holding tracks
+//in album order with their sidecars, and the cover of the first
+//track with art. lists adds the playlists.
+func album(fpath string, tracks []track, art *covers, lists bool, now time.Time) *fsutil.Dir {
+	sort.Slice(tracks, func(i, j int) bool { return tracks[i].less(tracks[j]) })
+	d := fsutil.CreateDir(path.Base(fpath))
+	covered := false
+	for _, t := range tracks {
+		name := uniqueName(d, t.name()+t.ext())
+		d.Append(newTrackfile(t, name))
+		addSidecars(d, t, name, now)
+		if c := art.of(t); c != nil && !covered {
+			d.Append(c)
+			covered = true
+		}
+	}
+	if lists {
+		addPlaylists(d, fpath, now)
+	}
+	return d
+}
+
+//cow copies the directories of a tree before they are changed,
+//readers of the tree it was copied from never see the changes.
+type cow map[*fsutil.Dir]bool
+
+//dir returns the directory name in d, which must have been copied,
+//copying it first if it is not yet. A missing directory is made
+//if create is set, otherwise nil is returned.
+func (c cow) dir(d *fsutil.Dir, name string, create bool) *fsutil.Dir {
+	var next *fsutil.Dir
+	if fi, err := d.Find(name); err == nil {
+		next, _ = fi.Sys().(*fsutil.Dir)
+	}
+	switch {
+	case next != nil && c[next]:
+		return next
+	case next != nil:
+		next = fsutil.CreateSortedDir(name, next.Copy()...)
+	case create:
+		next = fsutil.CreateSortedDir(name)
+	default:
+		return nil
+	}
+	c[next] = true
+	d.Append(next.Stats)
+	return next
 }
 
-func (fs *Jukefs) updateTree() {
-	fs.Lock()
-	albums := make(map[string]*fsutil.Dir)
-	for k, v := range fs.info {
-		a := v.Album()
-		a = strings.Replace(a, "/", "", -1)
-		t := strings.Replace(v.Title(), "/", "", -1)
-		if a == "" {
-			a = "UNDEFINED"
+//files splits the path of t below the root into its directories and name.
+func (fs *Jukefs) files(t track) ([]string, string) {
+	rel, err := filepath.Rel(fs.path, t.path)
+	if err != nil {
+		rel = filepath.Base(t.path)
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	return parts[:len(parts)-1], parts[len(parts)-1]
+}
+
+//mirror adds t, with its sidecars, to the files view.
+func (fs *Jukefs) mirror(c cow, view *fsutil.Dir, t track, now time.Time) {
+	dirs, name := fs.files(t)
+	d := view
+	for _, dname := range dirs {
+		d = c.dir(d, dname, true)
+	}
+	d.Append(newTrackfile(t, name))
+	addSidecars(d, t, name, now)
+}
+
+//unmirror removes t, with its sidecars, from the files view
+//and drops the directories it leaves empty.
+func (fs *Jukefs) unmirror(c cow, view *fsutil.Dir, t track) {
+	dirs, name := fs.files(t)
+	walked := []*fsutil.Dir{view}
+	for _, dname := range dirs {
+		d := c.dir(walked[len(walked)-1], dname, false)
+		if d == nil {
+			return
+		}
+		walked = append(walked, d)
+	}
+	d := walked[len(walked)-1]
+	d.Remove(name)
+	for _, ext := range sidecarExts {
+		d.Remove(name + ext)
+	}
+	for i := len(walked) - 1; i > 0 && len(walked[i].Copy()) == 0; i-- {
+		walked[i-1].Remove(dirs[i-1])
+	}
+}
+
+//hasDirs tells whether d holds a directory.
+func hasDirs(d *fsutil.Dir) bool {
+	for _, fi := range d.Copy() {
+		if fi.IsDir() {
+			return true
 		}
-		if d, ok := albums[a]; ok {
-			d.Append(fsutil.CreateFile([]byte(k), 0644, t).Stats)
-		} else {
-			newd := fsutil.CreateDir(a, fsutil.CreateFile([]byte(k), 0644, t).Stats)
-			albums[a] = newd
-			fs.root.Append(newd.Stats)
+	}
+	return false
+}
+
+//patch returns the views of info from those of old, which hold the
+//tracks of oldinfo, where the files at the paths in changed were
+//added, changed or removed since. Only the albums holding those
+//tracks are rebuilt and only their entries in the files view change,
+//the directories leading to them are copied and every other directory
+//is shared with old, which readers may still be walking.
+func (fs *Jukefs) patch(old *fsutil.Dir, oldinfo, info map[string]tag.Metadata, changed []string) *fsutil.Dir {
+	root := fsutil.CreateDir("/", old.Copy()...)
+	c := cow{root: true}
+	now := time.Now()
+	files := c.dir(root, "files", true)
+	//albums holds the tracks of the albums to rebuild, by their place
+	albums := make(map[string][]track)
+	for _, k := range changed {
+		if m, ok := oldinfo[k]; ok {
+			t := track{k, m}
+			for _, p := range t.places() {
+				albums[strings.Join(p, "/")] = nil
+			}
+			fs.unmirror(c, files, t)
+		}
+	}
+	for _, k := range changed {
+		if m, ok := info[k]; ok {
+			t := track{k, m}
+			for _, p := range t.places() {
+				albums[strings.Join(p, "/")] = nil
+			}
+			fs.mirror(c, files, t, now)
+		}
+	}
+	for k, m := range info {
+		t := track{k, m}
+		for _, p := range t.places() {
+			key := strings.Join(p, "/")
+			if list, ok := albums[key]; ok {
+				albums[key] = append(list, t)
+			}
+		}
+	}
+	art := newCovers(now)
+	//parents holds the artist, genre and year directories whose albums changed
+	parents := make(map[string]*fsutil.Dir)
+	for key, tracks := range albums {
+		p := strings.Split(key, "/")
+		d := root
+		for i, dname := range p[:len(p)-1] {
+			if d = c.dir(d, dname, len(tracks) > 0); d == nil {
+				break
+			}
+			if i > 0 {
+				parents[strings.Join(p[:i+1], "/")] = d
+			}
+		}
+		switch {
+		case d == nil:
+		case len(tracks) == 0:
+			d.Remove(p[len(p)-1])
+		default:
+			d.Append(album("/"+key, tracks, art, hasPlaylists(p[0]), now).Stats)
+		}
+	}
+	for key, d := range parents {
+		p := strings.Split(key, "/")
+		view := c.dir(root, p[0], false)
+		if !hasDirs(d) {
+			view.Remove(p[1])
+		} else if hasPlaylists(p[0]) {
+			addPlaylists(d, "/"+key, now)
 		}
 	}
-	fs.Unlock()
+	return root
+}
+
+//tree builds the views of the tracks in info:
+//albums/<album>, artists/<artist>/<album>, genres/<genre>/<album>
+//and years/<year>/<album> hold tracks in album order,
+//files mirrors the layout of the directory they were found in.
+//Every track has json and txt sidecars of its metadata, album directories
+//have a cover and album, artist and genre directories playlists of their tracks.
+func (fs *Jukefs) tree(info map[string]tag.Metadata) *fsutil.Dir {
+	root := fsutil.CreateDir("/")
+	for _, name := range []string{"albums", "artists", "genres", "years", "files"} {
+		root.Append(fsutil.CreateSortedDir(name).Stats)
+	}
+	if fi, err := fs.statPlaylists("/playlists"); err == nil {
+		root.Append(fi)
+	}
+	if fs.ctl != nil {
+		root.Append(fs.ctl.Content.Stats)
+	}
+	changed := make([]string, 0, len(info))
+	for k := range info {
+		changed = append(changed, k)
+	}
+	return fs.patch(root, nil, info, changed)
 }
 
 func (fs *Jukefs) Stat(fpath string) (os.FileInfo, error) {
@@ -112,6 +467,8 @@ func (fs *Jukefs) Stat(fpath string) (os.FileInfo, error) {
 	switch {
 	case fpath == "/":
 		return fs.root.Stat()
+	case fs.isPlaylists(fpath) && !strings.HasSuffix(fpath, "/index.html"):
+		return fs.statPlaylists(fpath)
 	case strings.HasSuffix(fpath, "/index.html"):
 		return fsutil.CreateFile([]byte{}, 0644, "index.html").Stats, nil
 	default:
@@ -122,21 +479,47 @@ func (fs *Jukefs) Stat(fpath string) (os.FileInfo, error) {
 func (fs *Jukefs) ReadDir(fpath string) (ffs.Dir, error) {
 	fs.RLock()
 	defer fs.RUnlock()
-	switch fpath {
-	case "/":
+	switch {
+	case fpath == "/":
 		return fs.root.Dup(), nil
+	case fpath == "/playlists":
+		fi, err := fs.listPlaylists()
+		if err != nil {
+			return nil, err
+		}
+		return fsutil.CreateSortedDir("playlists", fi...), nil
 	default:
 		return fs.root.WalkForDir(fpath)
 	}
 }
 
 func (fs *Jukefs) Open(fpath string, mode int) (ffs.File, error) {
+	//Opening ctl reads the status, which takes the lock itself
+	if fpath == "/ctl" {
+		return fs.ctl.Open(mode)
+	}
 	fs.RLock()
 	defer fs.RUnlock()
 	switch {
+	case fpath == "/playlists/index.html":
+		fi, err := fs.listPlaylists()
+		if err != nil {
+			return nil, err
+		}
+		f := fsutil.CreateFile([]byte{}, 0644, "index.html")
+		if err := dir2html(f, fi); err != nil {
+			return nil, err
+		}
+		return f.Open(os.O_RDONLY)
+	case fs.isPlaylists(fpath):
+		//User playlists are written straight to disk
+		return os.OpenFile(fs.playlistPath(fpath), mode&(os.O_RDONLY|os.O_WRONLY|os.O_RDWR|os.O_TRUNC), 0)
 	case fpath == "/index.html":
 		f := fsutil.CreateFile([]byte{}, 0644, "index.html")
-		return f.Dup(), dir2html(f, fs.root.Copy())
+		if err := dir2html(f, fs.root.Copy()); err != nil {
+			return nil, err
+		}
+		return f.Open(os.O_RDONLY)
 	case strings.HasSuffix(fpath, "/index.html"):
 		fpath = strings.TrimSuffix(fpath, "/index.html")
 		d, err := fs.root.WalkForDir(fpath)
@@ -144,29 +527,47 @@ func (fs *Jukefs) Open(fpath string, mode int) (ffs.File, error) {
 			return nil, err
 		}
 		f := fsutil.CreateFile([]byte{}, 0644, "index.html")
-		return f.Dup(), dir2html(f, d.Copy())
-	default:
-		f, err := fs.root.WalkForFile(fpath)
-		if err != nil {
+		if err := dir2html(f, d.Copy()); err != nil {
 			return nil, err
 		}
-		//These files store the path, not the file contents
-		f.Seek(0, io.SeekStart)
-		b, err := ioutil.ReadAll(f)
+		return f.Open(os.O_RDONLY)
+	default:
+		fi, err := fs.root.Walk(fpath)
 		if err != nil {
 			return nil, err
 		}
-		return os.OpenFile(string(b), os.O_RDONLY, 0555)
+		if o, ok := fi.(opener); ok {
+			return o.open()
+		}
+		return nil, os.ErrNotExist
 	}
 }
 
+//listing is a file as listed on an index page,
+//Type is the MIME type of tracks and empty otherwise.
+type listing struct {
+	Name  string
+	IsDir bool
+	Type  string
+}
+
+//dir2html writes the index page of fi, tracks are listed
+//with a player that plays them in turn from where one is picked.
 func dir2html(f ffs.Writer, fi []os.FileInfo) error {
 	t := template.New("page")
 	t, err := t.Parse(homepage)
 	if err != nil {
 		return err
 	}
-	return t.ExecuteTemplate(f, "page", fi)
+	l := make([]listing, 0, len(fi))
+	for _, info := range fi {
+		e := listing{Name: info.Name(), IsDir: info.IsDir()}
+		if tf, ok := info.(*trackfile); ok {
+			e.Type = tf.mime
+		}
+		l = append(l, e)
+	}
+	return t.ExecuteTemplate(f, "page", l)
 }
 
 const homepage = `
@@ -175,16 +576,53 @@ const homepage = `
 	<head>
 		<meta charset="utf-8">
 		<title>Jukefs</title>
+		<style>
+			.playing { font-weight: bold; }
+		</style>
 	</head>
 	<body>
+		<audio id="player" controls preload="none"></audio>
+		<button id="all">Play all</button>
+		<br>
 		{{ range . }}
 		{{ if .IsDir }}
 		<a href="{{.Name}}/index.html">{{.Name}}</a>
+		{{ else if .Type }}
+		<a class="track" href="{{.Name}}" data-type="{{.Type}}">{{.Name}}</a>
 		{{ else }}
 		<a href="{{.Name}}">{{.Name}}</a>
 		{{ end }}
 		<br>
 		{{ end }}
+		<script>
+			var player = document.getElementById("player");
+			var queue = Array.prototype.slice.call(document.querySelectorAll("a.track"));
+			var current = -1;
+			function play(i) {
+				if (current >= 0)
+					queue[current].classList.remove("playing");
+				current = i;
+				if (i < 0 || i >= queue.length) {
+					current = -1;
+					return;
+				}
+				queue[i].classList.add("playing");
+				player.src = queue[i].href;
+				player.play();
+			}
+			queue.forEach(function(a, i) {
+				a.addEventListener("click", function(e) {
+					e.preventDefault();
+					play(i);
+				});
+			});
+			player.addEventListener("ended", function() { play(current + 1); });
+			document.getElementById("all").addEventListener("click", function() { play(0); });
+			if (queue.length == 0) {
+				player.hidden = true;
+				document.getElementById("all").hidden = true;
+			}
+		</script>
 	</body>
 </html>
-`
\ No newline at end of file
+`
@@ -0,0 +1,97 @@
+package jukeboxfs
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/dhowden/tag"
+)
+
+type meta struct {
+	tag.Metadata
+	title, album, artist, genre string
+	year, track, disc           int
+	lyrics                      string
+	pic                         *tag.Picture
+}
+
+func (m meta) Title() string       { return m.title }
+func (m meta) Album() string       { return m.album }
+func (m meta) Artist() string      { return m.artist }
+func (m meta) AlbumArtist() string { return "" }
+func (m meta) Genre() string       { return m.genre }
+func (m meta) Year() int           { return m.year }
+func (m meta) Track() (int, int)   { return m.track, 0 }
+func (m meta) Disc() (int, int)    { return m.disc, 0 }
+func (m meta) Lyrics() string      { return m.lyrics }
+func (m meta) Picture() *tag.Picture {
+	return m.pic
+}
+
+func testJukefs(info map[string]tag.Metadata) *Jukefs {
+	fs := &Jukefs{RWMutex: &sync.RWMutex{}, path: "/music", playlists: "/music/.playlists", info: info}
+	fs.root = fs.tree(info)
+	return fs
+}
+
+//names lists fpath, leaving out the sidecars of tracks.
+func names(t *testing.T, fs *Jukefs, fpath string) (out []string) {
+	d, err := fs.ReadDir(fpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := d.Readdir(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range fi {
+		if _, ok := f.(*sidecar); !ok {
+			out = append(out, f.Name())
+		}
+	}
+	return
+}
+
+func expect(t *testing.T, fs *Jukefs, fpath string, want ...string) {
+	got := names(t, fs, fpath)
+	if len(got) != len(want) {
+		t.Fatalf("%s: expected %q got %q", fpath, want, got)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("%s: expected %q got %q", fpath, want, got)
+		}
+	}
+}
+
+func TestViews(t *testing.T) {
+	fs := testJukefs(map[string]tag.Metadata{
+		"/music/b/02.mp3":     meta{title: "Two", album: "B", artist: "Y", genre: "Rock", year: 1999, track: 2},
+		"/music/b/01.mp3":     meta{title: "One", album: "B", artist: "Y", genre: "Rock", year: 1999, track: 1},
+		"/music/b/10.mp3":     meta{title: "Ten", album: "B", artist: "Y", genre: "Rock", year: 1999, track: 10},
+		"/music/a/x.flac":     meta{title: "Same", album: "A/C", artist: "X"},
+		"/music/a/y.flac":     meta{title: "Same", album: "A/C", artist: "X"},
+		"/music/untagged.mp3": meta{},
+	})
+	expect(t, fs, "/", "albums", "artists", "genres", "years", "files", "playlists")
+	expect(t, fs, "/albums", "AC", "B", "Unknown")
+	expect(t, fs, "/albums/B", "01 - One.mp3", "02 - Two.mp3", "10 - Ten.mp3", "playlist.m3u8", "playlist.pls")
+	expect(t, fs, "/albums/AC", "Same.flac", "Same2.flac", "playlist.m3u8", "playlist.pls")
+	expect(t, fs, "/albums/Unknown", "untagged.mp3", "playlist.m3u8", "playlist.pls")
+	expect(t, fs, "/artists", "Unknown", "X", "Y")
+	expect(t, fs, "/artists/Y", "B", "playlist.m3u8", "playlist.pls")
+	expect(t, fs, "/artists/Y/B", "01 - One.mp3", "02 - Two.mp3", "10 - Ten.mp3", "playlist.m3u8", "playlist.pls")
+	expect(t, fs, "/genres", "Rock", "Unknown")
+	expect(t, fs, "/years", "1999", "Unknown")
+	expect(t, fs, "/years/1999/B", "01 - One.mp3", "02 - Two.mp3", "10 - Ten.mp3")
+	expect(t, fs, "/files", "a", "b", "untagged.mp3")
+	expect(t, fs, "/files/b", "01.mp3", "02.mp3", "10.mp3")
+
+	fi, err := fs.Stat("/artists/Y/B/10 - Ten.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tf, ok := fi.(*trackfile); !ok || tf.path != "/music/b/10.mp3" || tf.mime != "audio/mpeg" {
+		t.Fatalf("expected the track /music/b/10.mp3 got %v", fi)
+	}
+}
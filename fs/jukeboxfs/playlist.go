@@ -0,0 +1,211 @@
+package jukeboxfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/majiru/ffs"
+	"github.com/majiru/ffs/pkg/fsutil"
+)
+
+var ErrPlaylistName = errors.New("jukeboxfs: bad playlist name")
+
+//playlistNames are the playlists generated in each album,
+//artist and genre directory.
+var playlistNames = []string{"playlist.m3u8", "playlist.pls"}
+
+//playlist lists the tracks under dir, which is at fpath in the views.
+//It is its own os.FileInfo, the list is made when it is opened.
+//Its size is that of the list without a base URL, as it was when added.
+type playlist struct {
+	name  string
+	dir   *fsutil.Dir
+	fpath string
+	size  int64
+	mtime time.Time
+}
+
+func (p *playlist) Name() string       { return p.name }
+func (p *playlist) Size() int64        { return p.size }
+func (p *playlist) Mode() os.FileMode  { return 0444 }
+func (p *playlist) ModTime() time.Time { return p.mtime }
+func (p *playlist) IsDir() bool        { return false }
+func (p *playlist) Sys() interface{}   { return nil }
+
+//entry is a track in a playlist.
+type entry struct {
+	title string
+	url   string
+}
+
+//entries lists the tracks under d, at fpath, with their URLs on base.
+func entries(d *fsutil.Dir, fpath, base string) (out []entry) {
+	for _, fi := range d.Copy() {
+		if sys, ok := fi.Sys().(*fsutil.Dir); ok {
+			out = append(out, entries(sys, path.Join(fpath, fi.Name()), base)...)
+		} else if t, ok := fi.(*trackfile); ok {
+			title := strings.TrimSuffix(t.name, filepath.Ext(t.name))
+			out = append(out, entry{title, base + escape(path.Join(fpath, t.name))})
+		}
+	}
+	return
+}
+
+//escape escapes each element of fpath for use in a URL.
+func escape(fpath string) string {
+	parts := strings.Split(fpath, "/")
+	for i := range parts {
+		parts[i] = url.PathEscape(parts[i])
+	}
+	return strings.Join(parts, "/")
+}
+
+//render makes the playlist with the tracks on base,
+//without one the URLs are absolute paths on the same host.
+func (p *playlist) render(base string) []byte {
+	var b bytes.Buffer
+	list := entries(p.dir, p.fpath, base)
+	if path.Ext(p.name) == ".pls" {
+		b.WriteString("[playlist]\n")
+		for i, e := range list {
+			fmt.Fprintf(&b, "File%d=%s\nTitle%d=%s\nLength%d=-1\n", i+1, e.url, i+1, e.title, i+1)
+		}
+		fmt.Fprintf(&b, "NumberOfEntries=%d\nVersion=2\n", len(list))
+		return b.Bytes()
+	}
+	b.WriteString("#EXTM3U\n")
+	for _, e := range list {
+		fmt.Fprintf(&b, "#EXTINF:-1,%s\n%s\n", e.title, e.url)
+	}
+	return b.Bytes()
+}
+
+func (p *playlist) open() (ffs.File, error) {
+	h := &playlistHandle{p: p}
+	if err := h.fill(""); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+//playlistHandle is an open playlist. Over HTTP the URLs
+//are made full once the request is known.
+type playlistHandle struct {
+	*fsutil.File
+	p *playlist
+}
+
+func (h *playlistHandle) fill(base string) (err error) {
+	h.File, err = fsutil.CreateFile(h.p.render(base), 0444, h.p.name).Open(os.O_RDONLY)
+	return
+}
+
+func (h *playlistHandle) SetContext(ctx context.Context) {
+	if base, ok := ctx.Value(ffs.BaseURLKey{}).(string); ok {
+		h.fill(base)
+	}
+}
+
+//addPlaylists adds the playlists of d, which is at fpath,
+//once the tracks under it are in place.
+func addPlaylists(d *fsutil.Dir, fpath string, mtime time.Time) {
+	for _, name := range playlistNames {
+		p := &playlist{name: name, dir: d, fpath: fpath, mtime: mtime}
+		p.size = int64(len(p.render("")))
+		d.Append(p)
+	}
+}
+
+//named renames a file, the directory of user playlists
+//is hidden on disk.
+type named struct {
+	os.FileInfo
+	name string
+}
+
+func (n named) Name() string { return n.name }
+
+//playlistPath returns where fpath, under /playlists, is on disk.
+func (fs *Jukefs) playlistPath(fpath string) string {
+	return filepath.Join(fs.playlists, filepath.FromSlash(path.Clean("/"+strings.TrimPrefix(fpath, "/playlists"))))
+}
+
+//isPlaylists tells whether fpath is in the directory of user playlists.
+func (fs *Jukefs) isPlaylists(fpath string) bool {
+	return fpath == "/playlists" || strings.HasPrefix(fpath, "/playlists/")
+}
+
+//statPlaylists stats fpath under /playlists, which is empty
+//until the directory on disk is made.
+func (fs *Jukefs) statPlaylists(fpath string) (os.FileInfo, error) {
+	fi, err := os.Stat(fs.playlistPath(fpath))
+	if os.IsNotExist(err) && fpath == "/playlists" {
+		return fsutil.CreateSortedDir("playlists").Stats, nil
+	}
+	if err != nil {
+		return nil, os.ErrNotExist
+	}
+	if fpath == "/playlists" {
+		return named{fi, "playlists"}, nil
+	}
+	return fi, nil
+}
+
+//checkPlaylistName refuses names that are not a single visible file.
+func checkPlaylistName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") || name == "index.html" {
+		return ErrPlaylistName
+	}
+	return nil
+}
+
+//newPlaylist creates an empty user playlist, keeping any already there.
+//The directory of user playlists is made for the first.
+func (fs *Jukefs) newPlaylist(name string) error {
+	if err := checkPlaylistName(name); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(fs.playlists, 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(fs.playlists, name), os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (fs *Jukefs) removePlaylist(name string) error {
+	if err := checkPlaylistName(name); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(fs.playlists, name))
+}
+
+//listPlaylists lists the user playlists.
+func (fs *Jukefs) listPlaylists() ([]os.FileInfo, error) {
+	f, err := os.Open(fs.playlists)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Readdir(-1)
+	out := fi[:0]
+	for _, f := range fi {
+		if !f.IsDir() && checkPlaylistName(f.Name()) == nil {
+			out = append(out, f)
+		}
+	}
+	return out, err
+}
@@ -0,0 +1,127 @@
+package jukeboxfs
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dhowden/tag"
+	"github.com/majiru/ffs"
+	"github.com/majiru/ffs/pkg/server"
+)
+
+func readFile(t *testing.T, fs *Jukefs, fpath string) string {
+	f, err := fs.Open(fpath, os.O_RDONLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestPlaylists(t *testing.T) {
+	fs := testJukefs(map[string]tag.Metadata{
+		"/music/b/01.mp3": meta{title: "One", album: "B", artist: "Y", genre: "Rock", track: 1},
+		"/music/b/02.mp3": meta{title: "Two", album: "B", artist: "Y", genre: "Rock", track: 2},
+		"/music/c/01.mp3": meta{title: "Other", album: "C", artist: "Y", genre: "Rock", track: 1},
+	})
+	tests := []struct {
+		fpath  string
+		expect string
+	}{
+		{"/albums/B/playlist.m3u8", "#EXTM3U\n#EXTINF:-1,01 - One\n/albums/B/01%20-%20One.mp3\n#EXTINF:-1,02 - Two\n/albums/B/02%20-%20Two.mp3\n"},
+		{"/albums/B/playlist.pls", "[playlist]\nFile1=/albums/B/01%20-%20One.mp3\nTitle1=01 - One\nLength1=-1\n" +
+			"File2=/albums/B/02%20-%20Two.mp3\nTitle2=02 - Two\nLength2=-1\nNumberOfEntries=2\nVersion=2\n"},
+		{"/artists/Y/playlist.m3u8", "#EXTM3U\n#EXTINF:-1,01 - One\n/artists/Y/B/01%20-%20One.mp3\n#EXTINF:-1,02 - Two\n/artists/Y/B/02%20-%20Two.mp3\n" +
+			"#EXTINF:-1,01 - Other\n/artists/Y/C/01%20-%20Other.mp3\n"},
+	}
+	for _, test := range tests {
+		if s := readFile(t, fs, test.fpath); s != test.expect {
+			t.Fatalf("%s: expected %q got %q", test.fpath, test.expect, s)
+		}
+		fi, err := fs.Stat(test.fpath)
+		if err != nil || fi.Size() != int64(len(test.expect)) {
+			t.Fatalf("%s: bad stat %v %v", test.fpath, fi, err)
+		}
+	}
+
+	//Over HTTP the URLs are on the host asked for
+	srv := httptest.NewServer(server.Server{Fs: fs})
+	defer srv.Close()
+	r, err := http.Get(srv.URL + "/genres/Rock/C/playlist.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if expect := "#EXTM3U\n#EXTINF:-1,01 - Other\n" + srv.URL + "/genres/Rock/C/01%20-%20Other.mp3\n"; string(b) != expect {
+		t.Fatalf("expected %q got %q", expect, b)
+	}
+}
+
+func TestUserPlaylists(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jukeboxfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fs, err := NewJukefs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//Nothing is made on disk until the first playlist
+	expect(t, fs, "/playlists")
+	if _, err = os.Stat(filepath.Join(dir, ".playlists")); !os.IsNotExist(err) {
+		t.Fatalf("expected %v got %v", os.ErrNotExist, err)
+	}
+	if err = fs.ctl.Exec("newplaylist mine.m3u8"); err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range []string{"../x", ".hidden", "index.html"} {
+		if err = fs.ctl.Exec("newplaylist " + bad); err != ErrPlaylistName {
+			t.Fatalf("%s: expected %v got %v", bad, ErrPlaylistName, err)
+		}
+	}
+	f, err := fs.Open("/playlists/mine.m3u8", os.O_RDWR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const list = "#EXTM3U\n/albums/B/01%20-%20One.mp3\n"
+	if _, err = f.(ffs.Writer).Write([]byte(list)); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	fs.Close()
+
+	//Playlists are kept on disk
+	fs, err = NewJukefs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	expect(t, fs, "/playlists", "mine.m3u8")
+	if s := readFile(t, fs, "/playlists/mine.m3u8"); s != list {
+		t.Fatalf("expected %q got %q", list, s)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dir, ".playlists", "mine.m3u8")); err != nil || string(b) != list {
+		t.Fatalf("expected %q on disk got %q %v", list, b, err)
+	}
+	if _, err = fs.Stat("/playlists/../ctl"); err != os.ErrNotExist {
+		t.Fatalf("expected %v got %v", os.ErrNotExist, err)
+	}
+	if s := readFile(t, fs, "/playlists/index.html"); !strings.Contains(s, "mine.m3u8") {
+		t.Fatalf("playlist missing from index %q", s)
+	}
+	if err = fs.ctl.Exec("rmplaylist mine.m3u8"); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, fs, "/playlists")
+}
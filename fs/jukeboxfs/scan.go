@@ -0,0 +1,137 @@
+package jukeboxfs
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/majiru/ffs/pkg/ctl"
+	"github.com/majiru/ffs/pkg/fsutil"
+)
+
+var ErrWatch = errors.New("jukeboxfs: watching is not supported on this system")
+
+//stamp tells whether a file changed since it was read.
+type stamp struct {
+	size  int64
+	mtime time.Time
+}
+
+func (s stamp) same(o stamp) bool {
+	return s.size == o.size && s.mtime.Equal(o.mtime)
+}
+
+//Rescan reads the files under the root that are new or changed since
+//the last scan, and patches the views where any were added, changed
+//or removed. One rescan runs at a time, readers are only held off
+//while the new views replace the old.
+func (fs *Jukefs) Rescan() error {
+	fs.scan.Lock()
+	defer fs.scan.Unlock()
+	fs.RLock()
+	oldroot, old, oldstamps := fs.root, fs.info, fs.stamps
+	fs.RUnlock()
+	info, stamps, err := fs.readChanged(old, oldstamps)
+	if err != nil {
+		return err
+	}
+	var added, updated, removed int
+	var changed []string
+	for k := range info {
+		if _, ok := old[k]; !ok {
+			added++
+		} else if !stamps[k].same(oldstamps[k]) {
+			updated++
+		} else {
+			continue
+		}
+		changed = append(changed, k)
+	}
+	for k := range old {
+		if _, ok := info[k]; !ok {
+			removed++
+			changed = append(changed, k)
+		}
+	}
+	var root *fsutil.Dir
+	if len(changed) > 0 {
+		root = fs.patch(oldroot, old, info, changed)
+	}
+	fs.Lock()
+	fs.info, fs.stamps = info, stamps
+	if root != nil {
+		fs.root = root
+	}
+	fs.last = fmt.Sprintf("scanned %s: %d added, %d updated, %d removed",
+		time.Now().Format(time.RFC3339), added, updated, removed)
+	fs.Unlock()
+	return nil
+}
+
+//RescanEvery rescans every d until the Jukefs is closed.
+func (fs *Jukefs) RescanEvery(d time.Duration) {
+	go func() {
+		t := time.NewTicker(d)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				if err := fs.Rescan(); err != nil {
+					log.Println(err)
+				}
+			case <-fs.stop:
+				return
+			}
+		}
+	}()
+}
+
+//Close stops rescanning on a timer and watching for changes.
+func (fs *Jukefs) Close() error {
+	fs.closed.Do(func() { close(fs.stop) })
+	return nil
+}
+
+func (fs *Jukefs) newCtl() *ctl.Ctl {
+	c := ctl.New("ctl", 0644)
+	c.Register(ctl.Cmd{
+		Name: "rescan",
+		Help: "read new and changed files, dropping removed ones",
+		Fn: func(args []string) error {
+			return fs.Rescan()
+		},
+	})
+	c.Register(ctl.Cmd{
+		Name:    "newplaylist",
+		Args:    "name",
+		Help:    "create an empty playlist in /playlists",
+		MinArgs: 1,
+		MaxArgs: 1,
+		Fn: func(args []string) error {
+			return fs.newPlaylist(args[0])
+		},
+	})
+	c.Register(ctl.Cmd{
+		Name:    "rmplaylist",
+		Args:    "name",
+		Help:    "remove a playlist from /playlists",
+		MinArgs: 1,
+		MaxArgs: 1,
+		Fn: func(args []string) error {
+			return fs.removePlaylist(args[0])
+		},
+	})
+	c.Status = fs.status
+	return c
+}
+
+func (fs *Jukefs) status() string {
+	fs.RLock()
+	defer fs.RUnlock()
+	s := fmt.Sprintf("tracks %d\n%s\n", len(fs.info), fs.last)
+	if fs.watching {
+		s += "watching " + fs.path + "\n"
+	}
+	return s
+}
@@ -0,0 +1,120 @@
+package jukeboxfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTrack(t *testing.T, fpath, title string) {
+	if err := os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(fpath, id3("TIT2", title, "TALB", "Album"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func ctlWrite(t *testing.T, fs *Jukefs, cmd string) string {
+	f, err := fs.ctl.Open(os.O_RDWR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = f.Write([]byte(cmd)); err != nil {
+		t.Fatal(err)
+	}
+	f.Seek(0, 0)
+	b, _ := ioutil.ReadAll(f)
+	return string(b)
+}
+
+func TestRescan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jukeboxfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTrack(t, filepath.Join(dir, "a.mp3"), "A")
+	writeTrack(t, filepath.Join(dir, "b.mp3"), "B")
+	other := filepath.Join(dir, "other", "x.mp3")
+	os.Mkdir(filepath.Dir(other), 0755)
+	if err = ioutil.WriteFile(other, id3("TIT2", "X", "TALB", "Other", "TPE1", "Them", "TYER", "1999"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fs, err := NewJukefs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	expect(t, fs, "/albums/Album", "A.mp3", "B.mp3", "playlist.m3u8", "playlist.pls")
+	old := fs.root
+
+	//Nothing changed, the views are kept
+	if status := ctlWrite(t, fs, "rescan\n"); !strings.Contains(status, "0 added, 0 updated, 0 removed") {
+		t.Fatalf("unexpected status %q", status)
+	}
+	if fs.root != old {
+		t.Fatal("views rebuilt without changes")
+	}
+
+	os.Remove(filepath.Join(dir, "a.mp3"))
+	writeTrack(t, filepath.Join(dir, "b.mp3"), "Longer B")
+	writeTrack(t, filepath.Join(dir, "sub", "c.mp3"), "C")
+	status := ctlWrite(t, fs, "rescan\n")
+	if !strings.Contains(status, "tracks 3\n") || !strings.Contains(status, "1 added, 1 updated, 1 removed") {
+		t.Fatalf("unexpected status %q", status)
+	}
+	expect(t, fs, "/albums/Album", "Longer B.mp3", "C.mp3", "playlist.m3u8", "playlist.pls")
+	expect(t, fs, "/files", "b.mp3", "other", "sub")
+	//Only the changed albums are rebuilt, the old views are left as they were
+	before, _ := old.Walk("/artists/Them")
+	after, _ := fs.root.Walk("/artists/Them")
+	if before == nil || before.Sys() != after.Sys() {
+		t.Fatal("unchanged album rebuilt")
+	}
+	if _, err = old.Walk("/albums/Album/A.mp3"); err != nil {
+		t.Fatal("old views changed:", err)
+	}
+
+	expect(t, fs, "/years", "1999", "Unknown")
+
+	//The directories left empty are dropped
+	os.Remove(other)
+	ctlWrite(t, fs, "rescan\n")
+	expect(t, fs, "/artists", "Unknown")
+	expect(t, fs, "/years", "Unknown")
+	expect(t, fs, "/files", "b.mp3", "sub")
+}
+
+func TestWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jukeboxfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fs, err := NewJukefs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	if err = fs.Watch(); err == ErrWatch {
+		t.Skip(err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	os.Mkdir(filepath.Join(dir, "new"), 0755)
+	//Let the new directory be watched before writing to it
+	time.Sleep(100 * time.Millisecond)
+	writeTrack(t, filepath.Join(dir, "new", "a.mp3"), "A")
+	for i := 0; i < 50; i++ {
+		if _, err = fs.Stat("/albums/Album/A.mp3"); err == nil {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatal("track not found after change")
+}
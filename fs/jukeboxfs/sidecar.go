@@ -0,0 +1,195 @@
+package jukeboxfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dhowden/tag"
+	"github.com/majiru/ffs"
+	"github.com/majiru/ffs/pkg/fsutil"
+)
+
+//opener is implemented by the files of the views,
+//which are only made or reached on disk when opened.
+type opener interface {
+	open() (ffs.File, error)
+}
+
+//artNames are the images on disk taken as the cover of the tracks next to them.
+var artNames = []string{"folder.jpg", "folder.png", "cover.jpg", "cover.png"}
+
+//cover is the art of an album, embedded in a track or an image next to it.
+//It is its own os.FileInfo.
+type cover struct {
+	name string
+	pic  *tag.Picture
+	//path is the image on disk if pic is nil
+	path  string
+	size  int64
+	mtime time.Time
+}
+
+func (c *cover) Name() string       { return c.name }
+func (c *cover) Size() int64        { return c.size }
+func (c *cover) Mode() os.FileMode  { return 0444 }
+func (c *cover) ModTime() time.Time { return c.mtime }
+func (c *cover) IsDir() bool        { return false }
+func (c *cover) Sys() interface{}   { return nil }
+
+func (c *cover) open() (ffs.File, error) {
+	if c.pic == nil {
+		return os.Open(c.path)
+	}
+	return fsutil.CreateFile(c.pic.Data, 0444, c.name).Open(os.O_RDONLY)
+}
+
+//covers finds the art of tracks, images on disk are looked up
+//once for each directory.
+type covers struct {
+	dirs  map[string]*cover
+	mtime time.Time
+}
+
+func newCovers(mtime time.Time) *covers {
+	return &covers{make(map[string]*cover), mtime}
+}
+
+//of returns the cover of t, or nil if it has none.
+//Embedded art is preferred over images on disk.
+func (cs *covers) of(t track) *cover {
+	if p := t.m.Picture(); p != nil && len(p.Data) > 0 {
+		ext := strings.ToLower(p.Ext)
+		if ext != "png" && ext != "jpg" {
+			ext = "jpg"
+			if p.MIMEType == "image/png" {
+				ext = "png"
+			}
+		}
+		return &cover{"cover." + ext, p, "", int64(len(p.Data)), cs.mtime}
+	}
+	dir := filepath.Dir(t.path)
+	c, ok := cs.dirs[dir]
+	if ok {
+		return c
+	}
+	fi, _ := ioutil.ReadDir(dir)
+	for _, name := range artNames {
+		for _, f := range fi {
+			if !f.IsDir() && strings.EqualFold(f.Name(), name) {
+				c = &cover{"cover" + filepath.Ext(name), nil, filepath.Join(dir, f.Name()), f.Size(), f.ModTime()}
+				break
+			}
+		}
+		if c != nil {
+			break
+		}
+	}
+	cs.dirs[dir] = c
+	return c
+}
+
+//trackInfo is what a sidecar tells of a track.
+type trackInfo struct {
+	Title       string  `json:"title"`
+	Artist      string  `json:"artist"`
+	AlbumArtist string  `json:"albumartist,omitempty"`
+	Album       string  `json:"album"`
+	Track       int     `json:"track"`
+	Tracks      int     `json:"tracks,omitempty"`
+	Disc        int     `json:"disc"`
+	Discs       int     `json:"discs,omitempty"`
+	Year        int     `json:"year,omitempty"`
+	Genre       string  `json:"genre"`
+	Duration    float64 `json:"duration"`
+	Lyrics      string  `json:"lyrics,omitempty"`
+}
+
+func (t track) info() trackInfo {
+	i := trackInfo{
+		Title:       t.m.Title(),
+		Artist:      t.m.Artist(),
+		AlbumArtist: t.m.AlbumArtist(),
+		Album:       t.m.Album(),
+		Year:        t.m.Year(),
+		Genre:       t.m.Genre(),
+		Lyrics:      t.m.Lyrics(),
+	}
+	i.Track, i.Tracks = t.m.Track()
+	i.Disc, i.Discs = t.m.Disc()
+	if m, ok := t.m.(tagged); ok {
+		i.Duration = m.length.Round(time.Millisecond).Seconds()
+	}
+	return i
+}
+
+//sidecar holds the metadata of a track, as json or as text.
+//It is its own os.FileInfo, sized when it is added.
+type sidecar struct {
+	name  string
+	t     track
+	size  int64
+	mtime time.Time
+}
+
+//sidecarExts are the extensions of the sidecars of each track.
+var sidecarExts = []string{".json", ".txt"}
+
+func (s *sidecar) Name() string       { return s.name }
+func (s *sidecar) Size() int64        { return s.size }
+func (s *sidecar) Mode() os.FileMode  { return 0444 }
+func (s *sidecar) ModTime() time.Time { return s.mtime }
+func (s *sidecar) IsDir() bool        { return false }
+func (s *sidecar) Sys() interface{}   { return nil }
+
+func (s *sidecar) open() (ffs.File, error) {
+	return fsutil.CreateFile(s.render(), 0444, s.name).Open(os.O_RDONLY)
+}
+
+//render formats the metadata, text has a line for each field
+//followed by the lyrics.
+func (s *sidecar) render() []byte {
+	i := s.t.info()
+	if filepath.Ext(s.name) == ".json" {
+		b, _ := json.MarshalIndent(i, "", "\t")
+		return append(b, '\n')
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "title\t%s\nartist\t%s\n", i.Title, i.Artist)
+	if i.AlbumArtist != "" {
+		fmt.Fprintf(&b, "albumartist\t%s\n", i.AlbumArtist)
+	}
+	fmt.Fprintf(&b, "album\t%s\ntrack\t%d/%d\ndisc\t%d/%d\nyear\t%d\ngenre\t%s\nduration\t%s\n",
+		i.Album, i.Track, i.Tracks, i.Disc, i.Discs, i.Year, i.Genre, clock(s.t))
+	if i.Lyrics != "" {
+		fmt.Fprintf(&b, "\n%s\n", strings.TrimRight(i.Lyrics, "\n"))
+	}
+	return b.Bytes()
+}
+
+//clock formats the length of t as m:ss, or h:mm:ss.
+func clock(t track) string {
+	m, ok := t.m.(tagged)
+	if !ok {
+		return "0:00"
+	}
+	secs := int64(m.length.Round(time.Second).Seconds())
+	if secs >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+	}
+	return fmt.Sprintf("%d:%02d", secs/60, secs%60)
+}
+
+//addSidecars adds the sidecars of t, named after its file name in d.
+func addSidecars(d *fsutil.Dir, t track, name string, mtime time.Time) {
+	for _, ext := range sidecarExts {
+		s := &sidecar{name: name + ext, t: t, mtime: mtime}
+		s.size = int64(len(s.render()))
+		d.Append(s)
+	}
+}
@@ -0,0 +1,158 @@
+package jukeboxfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dhowden/tag"
+)
+
+func TestSidecars(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jukeboxfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "c"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "c", "Folder.JPG"), []byte("disk art"), 0644)
+	pic := &tag.Picture{Ext: "png", MIMEType: "image/png", Data: []byte("embedded art")}
+	info := map[string]tag.Metadata{
+		filepath.Join(dir, "b", "01.mp3"): tagged{meta{title: "One", album: "B", artist: "Y", genre: "Rock", year: 1999, track: 1, lyrics: "la la\n", pic: pic}, formatMP3, 3*time.Minute + 25*time.Second, 0, time.Time{}},
+		filepath.Join(dir, "b", "02.mp3"): meta{title: "Two", album: "B", artist: "Y", track: 2},
+		filepath.Join(dir, "c", "01.mp3"): meta{title: "Other", album: "C", artist: "Z", track: 1},
+		filepath.Join(dir, "d", "01.mp3"): meta{title: "Bare", album: "D", artist: "Z", track: 1},
+	}
+	fs := &Jukefs{RWMutex: &sync.RWMutex{}, path: dir, info: info}
+	fs.root = fs.tree(info)
+	expect(t, fs, "/albums/B", "01 - One.mp3", "cover.png", "02 - Two.mp3", "playlist.m3u8", "playlist.pls")
+	expect(t, fs, "/artists/Z/C", "01 - Other.mp3", "cover.jpg", "playlist.m3u8", "playlist.pls")
+	expect(t, fs, "/albums/D", "01 - Bare.mp3", "playlist.m3u8", "playlist.pls")
+	tests := []struct {
+		fpath  string
+		expect string
+	}{
+		{"/albums/B/cover.png", "embedded art"},
+		{"/years/Unknown/C/cover.jpg", "disk art"},
+		{"/albums/B/01 - One.mp3.json", `{
+	"title": "One",
+	"artist": "Y",
+	"album": "B",
+	"track": 1,
+	"disc": 0,
+	"year": 1999,
+	"genre": "Rock",
+	"duration": 205,
+	"lyrics": "la la\n"
+}
+`},
+		{"/albums/B/01 - One.mp3.txt", "title\tOne\nartist\tY\nalbum\tB\ntrack\t1/0\ndisc\t0/0\nyear\t1999\ngenre\tRock\nduration\t3:25\n\nla la\n"},
+		{"/files/b/02.mp3.txt", "title\tTwo\nartist\tY\nalbum\tB\ntrack\t2/0\ndisc\t0/0\nyear\t0\ngenre\t\nduration\t0:00\n"},
+	}
+	for _, test := range tests {
+		if s := readFile(t, fs, test.fpath); s != test.expect {
+			t.Fatalf("%s: expected %q got %q", test.fpath, test.expect, s)
+		}
+		fi, err := fs.Stat(test.fpath)
+		if err != nil || fi.Size() != int64(len(test.expect)) {
+			t.Fatalf("%s: bad stat %v %v", test.fpath, fi, err)
+		}
+	}
+	//Sidecars and covers are left out of playlists
+	if s := readFile(t, fs, "/albums/B/playlist.m3u8"); bytes.Count([]byte(s), []byte("#EXTINF")) != 2 {
+		t.Fatalf("unexpected playlist %q", s)
+	}
+}
+
+//extended encodes v as an 80 bit float.
+func extended(v float64) []byte {
+	frac, exp := math.Frexp(v)
+	b := make([]byte, 10)
+	binary.BigEndian.PutUint16(b, uint16(exp-1+16383))
+	binary.BigEndian.PutUint64(b[2:], uint64(frac*(1<<64)))
+	return b
+}
+
+func le32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, v)
+	return b
+}
+
+func be32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+func oggPage(granule uint64, packet []byte) []byte {
+	b := append([]byte("OggS"), 0, 0)
+	g := make([]byte, 8)
+	binary.LittleEndian.PutUint64(g, granule)
+	b = append(b, g...)
+	b = append(b, make([]byte, 12)...)
+	b = append(b, 1, byte(len(packet)))
+	return append(b, packet...)
+}
+
+func TestDuration(t *testing.T) {
+	flac := append([]byte("fLaC"), 0x80, 0, 0, 34)
+	info := make([]byte, 34)
+	//44100Hz, 2 channels, 16 bits, 441000 samples
+	info[10], info[11], info[12] = 0x0a, 0xc4, 0x42
+	binary.BigEndian.PutUint32(info[14:], 441000)
+	flac = append(flac, info...)
+
+	wavfmt := append(append([]byte{1, 0, 2, 0}, le32(44100)...), le32(176400)...)
+	wavfmt = append(wavfmt, 4, 0, 16, 0)
+	wav := chunked(true, "WAVE", []byte("fmt "), wavfmt, []byte("data"), make([]byte, 176400*2))
+
+	comm := append(append([]byte{0, 2}, be32(88200)...), 0, 16)
+	aiff := chunked(false, "AIFF", []byte("COMM"), append(comm, extended(44100)...))
+
+	//A 128kbit/s MPEG-1 layer III frame header
+	mp3 := append(id3("TIT2", "x"), 0xff, 0xfb, 0x90, 0x00)
+	mp3 = append(mp3, make([]byte, 16000*3-4)...)
+	xing := append(append([]byte{0xff, 0xfb, 0x90, 0x00}, make([]byte, 32)...), "Xing"...)
+	xing = append(append(append(xing, be32(1)...), be32(100)...), make([]byte, 1000)...)
+
+	mvhd := append([]byte{0, 0, 0, 0}, make([]byte, 8)...)
+	mvhd = append(append(append(mvhd, be32(1000)...), be32(90000)...), make([]byte, 80)...)
+	m4a := append(append(be32(16), "ftypM4A "...), 0, 0, 0, 0)
+	m4a = append(append(append(m4a, be32(uint32(8+8+len(mvhd)))...), "moov"...), append(append(be32(uint32(8+len(mvhd))), "mvhd"...), mvhd...)...)
+
+	vorbis := append(append([]byte("\x01vorbis"), 0, 0, 0, 0, 2), le32(44100)...)
+	vorbis = append(vorbis, make([]byte, 16)...)
+	ogg := append(oggPage(0, vorbis), oggPage(44100*4, []byte("x"))...)
+	opushead := append([]byte("OpusHead"), 1, 2, 0x38, 0x01)
+	opushead = append(opushead, make([]byte, 8)...)
+	opus := append(oggPage(0, opushead), oggPage(48000*5+312, []byte("x"))...)
+
+	tests := []struct {
+		b      []byte
+		format string
+		expect time.Duration
+	}{
+		{flac, formatFLAC, 10 * time.Second},
+		{wav, formatWAV, 2 * time.Second},
+		{aiff, formatAIFF, 2 * time.Second},
+		{mp3, formatMP3, 3 * time.Second},
+		{xing, formatMP3, 100 * 1152 * time.Second / 44100},
+		{m4a, formatM4A, 90 * time.Second},
+		{ogg, formatOGG, 4 * time.Second},
+		{opus, formatOpus, 5 * time.Second},
+		{[]byte("nothing"), formatFLAC, 0},
+	}
+	for _, test := range tests {
+		d := duration(bytes.NewReader(test.b), int64(len(test.b)), test.format)
+		if d.Round(time.Millisecond) != test.expect.Round(time.Millisecond) {
+			t.Fatalf("%s: expected %v got %v", test.format, test.expect, d)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package jukeboxfs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/majiru/ffs"
+)
+
+//mimeTypes are the MIME types tracks are served with, by format.
+var mimeTypes = map[string]string{
+	formatMP3:  "audio/mpeg",
+	formatFLAC: "audio/flac",
+	formatOGG:  "audio/ogg",
+	formatOpus: "audio/ogg; codecs=opus",
+	formatM4A:  "audio/mp4",
+	formatAAC:  "audio/aac",
+	formatWAV:  "audio/wav",
+	formatAIFF: "audio/aiff",
+}
+
+//format returns the format of t, as detected when it was read
+//or else as named by its extension.
+func (t track) format() string {
+	if m, ok := t.m.(tagged); ok && m.format != "" {
+		return m.format
+	}
+	return extFormat(t.path)
+}
+
+//ext returns the extension tracks in t's format are named with.
+func (t track) ext() string {
+	if f := t.format(); f != "" {
+		return "." + f
+	}
+	return strings.ToLower(filepath.Ext(t.path))
+}
+
+//trackfile is a track in the views. It is its own os.FileInfo,
+//with the size and time of the file it opens.
+type trackfile struct {
+	name  string
+	path  string
+	mime  string
+	size  int64
+	mtime time.Time
+}
+
+func newTrackfile(t track, name string) *trackfile {
+	f := &trackfile{name: name, path: t.path, mime: mimeTypes[t.format()]}
+	if m, ok := t.m.(tagged); ok {
+		f.size, f.mtime = m.size, m.mtime
+	}
+	return f
+}
+
+func (f *trackfile) Name() string       { return f.name }
+func (f *trackfile) Size() int64        { return f.size }
+func (f *trackfile) Mode() os.FileMode  { return 0444 }
+func (f *trackfile) ModTime() time.Time { return f.mtime }
+func (f *trackfile) IsDir() bool        { return false }
+func (f *trackfile) Sys() interface{}   { return nil }
+
+func (f *trackfile) open() (ffs.File, error) {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, err
+	}
+	return &trackHandle{file, f.mime}, nil
+}
+
+//trackHandle is an open track, it tells the HTTP server its MIME type
+//as names alone do not always have one.
+type trackHandle struct {
+	*os.File
+	mime string
+}
+
+func (h *trackHandle) ContentType() string {
+	return h.mime
+}
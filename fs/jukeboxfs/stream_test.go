@@ -0,0 +1,66 @@
+package jukeboxfs
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/majiru/ffs/pkg/server"
+)
+
+func TestStream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jukeboxfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	audio := append(id3("TIT2", "Song", "TALB", "Album"), bytes.Repeat([]byte{0xff, 0xfb, 0x90, 0x00}, 256)...)
+	if err = ioutil.WriteFile(filepath.Join(dir, "Song.FLAC"), audio, 0644); err != nil {
+		t.Fatal(err)
+	}
+	fs, err := NewJukefs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	//The extension follows the format found, not the one on disk
+	expect(t, fs, "/albums/Album", "Song.mp3", "playlist.m3u8", "playlist.pls")
+	fi, err := fs.Stat("/albums/Album/Song.mp3")
+	if err != nil || fi.Size() != int64(len(audio)) {
+		t.Fatalf("bad stat %v %v", fi, err)
+	}
+
+	srv := httptest.NewServer(server.Server{Fs: fs})
+	defer srv.Close()
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/albums/Album/Song.mp3", nil)
+	req.Header.Set("Range", "bytes=10-19")
+	r, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if r.StatusCode != http.StatusPartialContent || !bytes.Equal(b, audio[10:20]) {
+		t.Fatalf("expected a partial response of %q got %s %q", audio[10:20], r.Status, b)
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "audio/mpeg" {
+		t.Fatalf("expected audio/mpeg got %q", ct)
+	}
+
+	r, err = http.Get(srv.URL + "/albums/Album/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ = ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	for _, want := range []string{"<audio", `class="track" href="Song.mp3" data-type="audio/mpeg"`, `href="playlist.m3u8"`} {
+		if !strings.Contains(string(b), want) {
+			t.Fatalf("%q missing from index %q", want, b)
+		}
+	}
+}
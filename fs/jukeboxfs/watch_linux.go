@@ -0,0 +1,121 @@
+//go:build linux
+// +build linux
+
+package jukeboxfs
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+//settle is how long the directory has to be left alone
+//after a change before it is rescanned.
+var settle = time.Second
+
+const watchMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_CLOSE_WRITE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF
+
+//watcher rescans a Jukefs when inotify reports a change under its root.
+type watcher struct {
+	sync.Mutex
+	fs *Jukefs
+	//fd is kept apart from f, f.Fd would make f blocking
+	fd   int
+	f    *os.File
+	dirs map[int32]string
+}
+
+//Watch rescans the directory, once it settles, whenever files
+//are added, written, moved or removed under it. Watching stops
+//when the Jukefs is closed.
+func (fs *Jukefs) Watch() error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return err
+	}
+	//A non blocking file is read through the runtime poller,
+	//so closing it ends a pending read
+	w := &watcher{fs: fs, fd: fd, f: os.NewFile(uintptr(fd), "inotify"), dirs: make(map[int32]string)}
+	if err = w.add(fs.path); err != nil {
+		w.f.Close()
+		return err
+	}
+	fs.Lock()
+	fs.watching = true
+	fs.Unlock()
+	go func() {
+		<-fs.stop
+		w.f.Close()
+	}()
+	go w.run()
+	return nil
+}
+
+//add watches dir and every directory under it.
+func (w *watcher) add(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		if path == w.fs.playlists {
+			return filepath.SkipDir
+		}
+		wd, err := syscall.InotifyAddWatch(w.fd, path, watchMask)
+		if err != nil {
+			return err
+		}
+		w.Lock()
+		w.dirs[int32(wd)] = path
+		w.Unlock()
+		return nil
+	})
+}
+
+func (w *watcher) run() {
+	defer func() {
+		w.fs.Lock()
+		w.fs.watching = false
+		w.fs.Unlock()
+	}()
+	timer := time.AfterFunc(time.Hour, func() {
+		if err := w.fs.Rescan(); err != nil {
+			log.Println(err)
+		}
+	})
+	timer.Stop()
+	defer timer.Stop()
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.f.Read(buf)
+		if err != nil {
+			return
+		}
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			name := buf[off+syscall.SizeofInotifyEvent : off+syscall.SizeofInotifyEvent+int(ev.Len)]
+			off += syscall.SizeofInotifyEvent + int(ev.Len)
+			w.Lock()
+			dir, ok := w.dirs[ev.Wd]
+			if ev.Mask&syscall.IN_IGNORED != 0 {
+				delete(w.dirs, ev.Wd)
+			}
+			w.Unlock()
+			//New directories are watched as well
+			if ok && ev.Mask&syscall.IN_ISDIR != 0 && ev.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+				if i := bytes.IndexByte(name, 0); i >= 0 {
+					name = name[:i]
+				}
+				if err := w.add(filepath.Join(dir, string(name))); err != nil {
+					log.Println(err)
+				}
+			}
+		}
+		timer.Reset(settle)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package jukeboxfs
+
+//Watch is only supported on Linux.
+func (fs *Jukefs) Watch() error {
+	return ErrWatch
+}
@@ -2,6 +2,7 @@ package mediafs
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"io/ioutil"
 	"log"
@@ -12,16 +13,18 @@ import (
 
 	anidb "github.com/majiru/anidb2json"
 	"github.com/majiru/ffs"
-	"github.com/majiru/ffs/pkg/chanfile"
 	"github.com/majiru/ffs/pkg/fsutil"
+	"github.com/majiru/ffs/pkg/synthfile"
 )
 
+var ErrSearch = errors.New("mediafs: expected search=name")
+
 type Mediafs struct {
 	*sync.RWMutex
 	Root       *fsutil.Dir
 	DB         *anidb.TitleDB
-	dbfile     *chanfile.File
-	searchfile *chanfile.File
+	dbfile     *synthfile.File
+	searchfile *synthfile.File
 	homepage   *fsutil.File
 	Tags       *fsutil.Dir
 	Staff      *fsutil.Dir
@@ -32,12 +35,17 @@ func NewMediafs(db io.ReadWriter) (fs *Mediafs, err error) {
 		&sync.RWMutex{},
 		nil,
 		&anidb.TitleDB{},
-		chanfile.CreateFile([]byte(""), 0644, "db"),
-		chanfile.CreateFile([]byte(""), 0644, "search"),
+		nil,
+		nil,
 		fsutil.CreateFile([]byte(""), 0644, "index.html"),
 		nil,
 		nil,
 	}
+	//The tree is regenerated once a writer is done with the db
+	fs.dbfile = synthfile.CreateFile([]byte(""), 0644, "db", synthfile.Handler{
+		OnClose: func(h *synthfile.Handle) error { return fs.Check() },
+	})
+	fs.searchfile = synthfile.CreateQuery(0644, "search", fs.searchQuery)
 	if db != nil {
 		_, err = io.Copy(fs.dbfile.Content, db)
 		if err != nil {
@@ -47,8 +55,6 @@ func NewMediafs(db io.ReadWriter) (fs *Mediafs, err error) {
 		err = fs.update()
 	}
 	fs.updateTree()
-	go fs.dbproc()
-	go fs.searchproc()
 	return
 }
 
@@ -95,7 +101,7 @@ func (fs *Mediafs) updateTree() {
 }
 
 func (fs *Mediafs) updateDB() error {
-	fs.dbfile.Seek(0, io.SeekStart)
+	fs.dbfile.Content.Seek(0, io.SeekStart)
 	b, err := ioutil.ReadAll(fs.dbfile.Content)
 	if err != nil {
 		return err
@@ -122,22 +128,6 @@ func (fs *Mediafs) Check() (error) {
 	return nil
 }
 
-func (fs *Mediafs) dbproc() {
-	for {
-		m := <-fs.dbfile.Req
-		switch m.Type {
-		case chanfile.Read:
-			fs.dbfile.Recv <- chanfile.RecvMsg{chanfile.Commit, nil}
-		case chanfile.Write:
-			fs.dbfile.Recv <- chanfile.RecvMsg{chanfile.Commit, nil}
-		case chanfile.Trunc:
-			fs.dbfile.Recv <- chanfile.RecvMsg{chanfile.Commit, nil}
-		case chanfile.Close:
-			fs.dbfile.Recv <- chanfile.RecvMsg{chanfile.Commit, fs.Check()}
-		}
-	}
-}
-
 func (fs *Mediafs) search(name string) []*anidb.Anime {
 	var result []*anidb.Anime
 	name = strings.ToLower(name)
@@ -151,27 +141,14 @@ func (fs *Mediafs) search(name string) []*anidb.Anime {
 	return result
 }
 
-func (fs *Mediafs) searchproc() {
-	for {
-		m := <-fs.searchfile.Req
-		switch m.Type {
-		case chanfile.Read:
-			fs.searchfile.Recv <- chanfile.RecvMsg{chanfile.Commit, nil}
-		case chanfile.Write:
-			parts := strings.Split(string(m.Content), "=")
-			if len(parts) != 2 {
-				fs.searchfile.Recv <- chanfile.RecvMsg{chanfile.Discard, nil}
-				continue
-			}
-			err := fs.genpage(fs.searchfile.Content, fs.search(parts[1]))
-			fs.searchfile.Content.Seek(0, io.SeekStart)
-			fs.searchfile.Recv <- chanfile.RecvMsg{chanfile.Discard, err}
-		case chanfile.Trunc:
-			fs.searchfile.Recv <- chanfile.RecvMsg{chanfile.Commit, nil}
-		case chanfile.Close:
-			fs.searchfile.Recv <- chanfile.RecvMsg{chanfile.Commit, nil}
-		}
+//searchQuery answers queries of the form search=name,
+//as sent by the search form of the homepage.
+func (fs *Mediafs) searchQuery(reply ffs.Writer, query []byte) error {
+	parts := strings.Split(string(query), "=")
+	if len(parts) != 2 {
+		return ErrSearch
 	}
+	return fs.genpage(reply, fs.search(parts[1]))
 }
 
 func (fs *Mediafs) dir2slice(f *fsutil.Dir) []*anidb.Anime {
@@ -229,9 +206,10 @@ func (fs *Mediafs) createPageFromDir(dir string) (*fsutil.File, error) {
 		return nil, err
 	}
 	f := fsutil.CreateFile([]byte(""), 0644, "index.html")
-	err = fs.genpage(f, fs.dir2slice(d))
-	f.Seek(0, io.SeekStart)
-	return f, nil
+	if err = fs.genpage(f, fs.dir2slice(d)); err != nil {
+		return nil, err
+	}
+	return f.Open(os.O_RDONLY)
 }
 
 func (fs *Mediafs) Open(file string, mode int) (ffs.File, error) {
@@ -245,17 +223,16 @@ func (fs *Mediafs) Open(file string, mode int) (ffs.File, error) {
 	case strings.HasPrefix(file, "/tags"), strings.HasPrefix(file, "/staff"):
 		return fs.createPageFromDir(file)
 	case file == "/index.html":
-		return fs.homepage.Dup(), nil
+		return fs.homepage.Open(os.O_RDONLY)
 	case file == "/db":
-		return fs.dbfile.Dup(), nil
+		return fs.dbfile.Open(mode)
 	case file == "/search":
-		return fs.searchfile.Dup(), nil
+		return fs.searchfile.Open(mode)
 	default:
-		if f, err := fs.Root.WalkForFile(file); err != nil {
+		if f, err := fs.Root.OpenFile(file, os.O_RDONLY); err != nil {
 			return nil, err
 		} else {
 			//These files store the absolute path, not the file contents
-			f.Seek(0, io.SeekStart)
 			if b, err := ioutil.ReadAll(f); err != nil {
 				return nil, err
 			} else {
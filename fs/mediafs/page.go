@@ -86,9 +86,10 @@ func (fs *Mediafs) handlePagination(req string, shows []*anidb.Anime) (ffs.Write
 		return nil, os.ErrNotExist
 	}
 	content := fsutil.CreateFile([]byte(""), 0644, file)
-	err = fs.genwindow(content, shows, pagenum)
-	content.Seek(0, io.SeekStart)
-	return content.Dup(), err
+	if err = fs.genwindow(content, shows, pagenum); err != nil {
+		return nil, err
+	}
+	return content.Open(os.O_RDONLY)
 }
 
 const homepagetemplate = `
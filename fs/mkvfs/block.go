@@ -0,0 +1,184 @@
+package mkvfs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrBlock = errors.New("mkvfs: malformed block")
+
+//Lacing modes as stored in bits 1-2 of a block's flags.
+const (
+	NoLacing    = 0x0
+	XiphLacing  = 0x2
+	FixedLacing = 0x4
+	EBMLLacing  = 0x6
+)
+
+var lacingNames = map[byte]string{
+	NoLacing:    "No Lacing",
+	XiphLacing:  "Xiph Lacing",
+	FixedLacing: "Fixed-size Lacing",
+	EBMLLacing:  "EBML Lacing",
+}
+
+//Frame is one frame of a block, Offset is counted
+//from the start of the block's data.
+type Frame struct {
+	Offset int64
+	Size   int64
+}
+
+//Block is the decoded header and frame layout of a Block or SimpleBlock.
+//Keyframe and Discardable only exist in a SimpleBlock's flags,
+//for a Block they are given by its BlockGroup and are always false here.
+type Block struct {
+	Simple      bool
+	Track       uint64
+	Timecode    int16
+	Keyframe    bool
+	Invisible   bool
+	Discardable bool
+	Lacing      string
+	Frames      []Frame
+}
+
+//parseBlock decodes the data of a block, simple tells
+//whether it is a SimpleBlock. The frames are checked to fit in b.
+func parseBlock(b []byte, simple bool) (*Block, error) {
+	track, n, err := vint(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < n+3 {
+		return nil, ErrBlock
+	}
+	blk := &Block{Simple: simple, Track: track}
+	blk.Timecode = int16(binary.BigEndian.Uint16(b[n:]))
+	flags := b[n+2]
+	blk.Invisible = flags&0x08 != 0
+	if simple {
+		blk.Keyframe = flags&0x80 != 0
+		blk.Discardable = flags&0x01 != 0
+	}
+	lacing := flags & 0x06
+	blk.Lacing = lacingNames[lacing]
+	pos := n + 3
+	if lacing == NoLacing {
+		blk.Frames = []Frame{{int64(pos), int64(len(b) - pos)}}
+		return blk, nil
+	}
+	if len(b) <= pos {
+		return nil, ErrBlock
+	}
+	count := int(b[pos]) + 1
+	pos++
+	sizes := make([]int64, count)
+	switch lacing {
+	case XiphLacing:
+		for i := 0; i < count-1; i++ {
+			for {
+				if pos >= len(b) {
+					return nil, ErrBlock
+				}
+				sizes[i] += int64(b[pos])
+				pos++
+				if b[pos-1] != 0xff {
+					break
+				}
+			}
+		}
+	case FixedLacing:
+		if (len(b)-pos)%count != 0 {
+			return nil, ErrBlock
+		}
+		for i := range sizes {
+			sizes[i] = int64((len(b) - pos) / count)
+		}
+	case EBMLLacing:
+		first, n, err := vint(b[pos:])
+		if err != nil {
+			return nil, err
+		}
+		pos += n
+		sizes[0] = int64(first)
+		for i := 1; i < count-1; i++ {
+			diff, n, err := svint(b[pos:])
+			if err != nil {
+				return nil, err
+			}
+			pos += n
+			sizes[i] = sizes[i-1] + diff
+		}
+	}
+	//Xiph and EBML lacing leave the last frame with what remains
+	if lacing != FixedLacing {
+		rest := int64(len(b) - pos)
+		for _, s := range sizes[:count-1] {
+			if s < 0 || s > rest {
+				return nil, ErrBlock
+			}
+			rest -= s
+		}
+		sizes[count-1] = rest
+	}
+	off := int64(pos)
+	blk.Frames = make([]Frame, count)
+	for i, s := range sizes {
+		blk.Frames[i] = Frame{off, s}
+		off += s
+	}
+	return blk, nil
+}
+
+//String describes the block as it is shown by the data file.
+func (blk *Block) String() string {
+	var sb strings.Builder
+	name := "Block"
+	if blk.Simple {
+		name = "SimpleBlock"
+	}
+	fmt.Fprintf(&sb, "%s Header:\n\tTrack number: %d\n\tRelative Timecode: %d\n\tFlags:\n", name, blk.Track, blk.Timecode)
+	if blk.Simple {
+		fmt.Fprintf(&sb, "\t\tKeyframe: %t\n", blk.Keyframe)
+	}
+	fmt.Fprintf(&sb, "\t\tInvisible: %t\n", blk.Invisible)
+	if blk.Simple {
+		fmt.Fprintf(&sb, "\t\tDiscardable: %t\n", blk.Discardable)
+	}
+	fmt.Fprintf(&sb, "\t\tLacing: %s\n\tNumber of frames: %d\n\tFrames:\n", blk.Lacing, len(blk.Frames))
+	for i, f := range blk.Frames {
+		fmt.Fprintf(&sb, "\t\tFrame %d: %d Bytes at %d\n", i+1, f.Size, f.Offset)
+	}
+	return sb.String()
+}
+
+//vint decodes an EBML variable length integer with its marker removed,
+//n is the number of bytes it took.
+func vint(b []byte) (v uint64, n int, err error) {
+	if len(b) == 0 || b[0] == 0 {
+		return 0, 0, ErrBlock
+	}
+	for n = 1; b[0]&(0x80>>uint(n-1)) == 0; n++ {
+	}
+	if len(b) < n {
+		return 0, 0, ErrBlock
+	}
+	v = uint64(b[0] & (0xff >> uint(n)))
+	for _, c := range b[1:n] {
+		v = v<<8 | uint64(c)
+	}
+	return v, n, nil
+}
+
+//svint decodes a signed EBML lace size difference,
+//stored with a bias of half the range of its width.
+func svint(b []byte) (int64, int, error) {
+	v, n, err := vint(b)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int64(v) - (int64(1)<<uint(7*n-1) - 1), n, nil
+}
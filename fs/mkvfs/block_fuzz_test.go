@@ -0,0 +1,23 @@
+//go:build go1.18
+// +build go1.18
+
+package mkvfs
+
+import "testing"
+
+func FuzzParseBlock(f *testing.F) {
+	f.Add([]byte{0x81, 0x00, 0x10, 0x80, 'a', 'b', 'c', 'd'}, true)
+	f.Add([]byte{0x82, 0xff, 0xfe, 0x02, 0x02, 0xff, 0x01, 0x02, 1, 2, 3}, false)
+	f.Add([]byte{0x81, 0x00, 0x00, 0x04, 0x01, 1, 2, 3, 4, 5, 6}, true)
+	f.Add([]byte{0x81, 0x00, 0x00, 0x06, 0x02, 0x83, 0xc0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, true)
+	f.Fuzz(func(t *testing.T, b []byte, simple bool) {
+		blk, err := parseBlock(b, simple)
+		if err != nil {
+			return
+		}
+		checkFrames(t, blk, b)
+		if !simple && (blk.Keyframe || blk.Discardable) {
+			t.Fatalf("Block has SimpleBlock flags for %x", b)
+		}
+	})
+}
@@ -0,0 +1,170 @@
+package mkvfs
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//checkFrames verifies that frames follow each other and end with b.
+func checkFrames(t *testing.T, blk *Block, b []byte) {
+	if len(blk.Frames) == 0 {
+		t.Fatalf("no frames for %x", b)
+	}
+	off := blk.Frames[0].Offset
+	for _, f := range blk.Frames {
+		if f.Offset != off || f.Size < 0 {
+			t.Fatalf("bad frame %v in %v for %x", f, blk.Frames, b)
+		}
+		off += f.Size
+	}
+	if off != int64(len(b)) {
+		t.Fatalf("expected frames to end at %d got %d for %x", len(b), off, b)
+	}
+}
+
+func TestParseBlock(t *testing.T) {
+	xiph := append([]byte{0x82, 0xff, 0xfe, 0x02, 0x02, 0xff, 0x01, 0x02}, make([]byte, 261)...)
+	tests := []struct {
+		b      []byte
+		simple bool
+		expect Block
+		err    error
+	}{
+		{
+			[]byte{0x81, 0x00, 0x10, 0x80, 'a', 'b', 'c', 'd'}, true,
+			Block{true, 1, 16, true, false, false, "No Lacing", []Frame{{4, 4}}}, nil,
+		},
+		{
+			[]byte{0x81, 0x00, 0x00, 0x89, 'a'}, false,
+			Block{false, 1, 0, false, true, false, "No Lacing", []Frame{{4, 1}}}, nil,
+		},
+		{
+			[]byte{0x81, 0x00, 0x00, 0x09}, true,
+			Block{true, 1, 0, false, true, true, "No Lacing", []Frame{{4, 0}}}, nil,
+		},
+		{
+			xiph, true,
+			Block{true, 2, -2, false, false, false, "Xiph Lacing", []Frame{{8, 256}, {264, 2}, {266, 3}}}, nil,
+		},
+		{
+			[]byte{0x81, 0x00, 0x00, 0x04, 0x01, 1, 2, 3, 4, 5, 6}, true,
+			Block{true, 1, 0, false, false, false, "Fixed-size Lacing", []Frame{{5, 3}, {8, 3}}}, nil,
+		},
+		{
+			[]byte{0x81, 0x00, 0x00, 0x06, 0x02, 0x83, 0xc0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, true,
+			Block{true, 1, 0, false, false, false, "EBML Lacing", []Frame{{7, 3}, {10, 4}, {14, 2}}}, nil,
+		},
+		{
+			[]byte{0x40, 0x01, 0x00, 0x00, 0x06, 0x02, 0x83, 0x5f, 0xfe, 1, 2, 3, 4, 5, 6}, true,
+			Block{true, 1, 0, false, false, false, "EBML Lacing", []Frame{{9, 3}, {12, 2}, {14, 1}}}, nil,
+		},
+		{[]byte{}, true, Block{}, ErrBlock},
+		{[]byte{0x00, 0x00, 0x00, 0x00}, true, Block{}, ErrBlock},
+		{[]byte{0x81, 0x00}, true, Block{}, ErrBlock},
+		{[]byte{0x81, 0x00, 0x00, 0x02}, true, Block{}, ErrBlock},
+		{[]byte{0x81, 0x00, 0x00, 0x02, 0x01, 0xff}, true, Block{}, ErrBlock},
+		{[]byte{0x81, 0x00, 0x00, 0x02, 0x01, 0x05, 1, 2}, true, Block{}, ErrBlock},
+		{[]byte{0x81, 0x00, 0x00, 0x04, 0x01, 1, 2, 3}, true, Block{}, ErrBlock},
+		{[]byte{0x81, 0x00, 0x00, 0x06, 0x02, 0x81, 0x80, 1, 2, 3}, true, Block{}, ErrBlock},
+	}
+	for _, test := range tests {
+		blk, err := parseBlock(test.b, test.simple)
+		if err != test.err {
+			t.Fatalf("expected %v got %v for %x", test.err, err, test.b)
+		}
+		if err != nil {
+			continue
+		}
+		if !reflect.DeepEqual(*blk, test.expect) {
+			t.Fatalf("expected %+v got %+v for %x", test.expect, *blk, test.b)
+		}
+		checkFrames(t, blk, test.b)
+	}
+}
+
+func TestVint(t *testing.T) {
+	tests := []struct {
+		b []byte
+		v uint64
+		n int
+	}{
+		{[]byte{0x81}, 1, 1},
+		{[]byte{0xff}, 127, 1},
+		{[]byte{0x40, 0x02}, 2, 2},
+		{[]byte{0x20, 0x01, 0x00}, 256, 3},
+		{[]byte{0x01, 0, 0, 0, 0, 0, 0, 0x05}, 5, 8},
+	}
+	for _, test := range tests {
+		v, n, err := vint(test.b)
+		if err != nil || v != test.v || n != test.n {
+			t.Fatalf("expected %d %d <nil> got %d %d %v for %x", test.v, test.n, v, n, err, test.b)
+		}
+	}
+	if _, _, err := vint([]byte{0x20, 0x01}); err != ErrBlock {
+		t.Fatalf("expected %v got %v", ErrBlock, err)
+	}
+	if v, _, _ := svint([]byte{0x80}); v != -63 {
+		t.Fatalf("expected %d got %d", -63, v)
+	}
+	if v, _, _ := svint([]byte{0x01, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}); v != 0 {
+		t.Fatalf("expected %d got %d", 0, v)
+	}
+}
+
+func query(t *testing.T, d *Decoder, q string) (string, error) {
+	h, err := d.Data.Open(os.O_RDWR)
+	if err != nil {
+		t.Fatal("error opening data:", err)
+	}
+	defer h.Close()
+	if _, err = h.Write([]byte(q)); err != nil {
+		return "", err
+	}
+	h.Seek(0, io.SeekStart)
+	b, err := ioutil.ReadAll(h)
+	return string(b), err
+}
+
+func TestDecoder(t *testing.T) {
+	f, err := ioutil.TempFile("", "mkvfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Write([]byte("junk"))
+	f.Write([]byte{0x81, 0x00, 0x00, 0x84, 0x01, 1, 2, 3, 4, 5, 6})
+	d := NewDecoder()
+	if _, err = query(t, d, "4 11"); err != ErrNotLoaded {
+		t.Fatalf("expected %v got %v", ErrNotLoaded, err)
+	}
+	d.f = f
+	out, err := query(t, d, "4 11 simple")
+	if err != nil {
+		t.Fatal("error decoding block:", err)
+	}
+	for _, line := range []string{"SimpleBlock Header", "Keyframe: true", "Fixed-size Lacing", "Frame 2: 3 Bytes at 12"} {
+		if !strings.Contains(out, line) {
+			t.Fatalf("expected %q in %q", line, out)
+		}
+	}
+	out, err = query(t, d, "4 11 json")
+	if err != nil {
+		t.Fatal("error decoding block:", err)
+	}
+	if !strings.Contains(out, `"Simple": false`) || !strings.Contains(out, `"Offset": 9`) {
+		t.Fatalf("unexpected json %q", out)
+	}
+	for _, q := range []string{"", "4", "a 11", "4 -1", "4 11 loud"} {
+		if _, err = query(t, d, q); err != ErrUsage {
+			t.Fatalf("expected %v got %v for %q", ErrUsage, err, q)
+		}
+	}
+	if _, err = query(t, d, "4 100"); err == nil || bytes.Contains([]byte(err.Error()), []byte("usage")) {
+		t.Fatalf("expected read error got %v", err)
+	}
+}
@@ -0,0 +1,244 @@
+package mkvfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/remko/go-mkvparse"
+)
+
+var ErrElement = errors.New("mkvfs: malformed element")
+
+//epoch is the date EBML dates are counted from.
+var epoch = time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+//ebmlParser reads an EBML document, handing its elements to
+//a mkvparse.Handler as they are typed by a Schema.
+type ebmlParser struct {
+	r   io.Reader
+	s   *Schema
+	off int64
+	//next is an ID read while looking for the end of an element
+	//of unknown size, it starts the element read after it
+	next    mkvparse.ElementID
+	hasNext bool
+}
+
+//parseEBML parses r with s, like mkvparse.Parse.
+//Unknown elements are handed over as binary.
+func parseEBML(r io.Reader, s *Schema, h mkvparse.Handler) error {
+	p := &ebmlParser{r: r, s: s}
+	return p.elements(-1, 0, -1, h)
+}
+
+func (p *ebmlParser) read(b []byte) error {
+	n, err := io.ReadFull(p.r, b)
+	p.off += int64(n)
+	if err == io.EOF && n == 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+//readID returns io.EOF only if the document ends before the ID.
+func (p *ebmlParser) readID() (mkvparse.ElementID, error) {
+	if p.hasNext {
+		p.hasNext = false
+		return p.next, nil
+	}
+	b := make([]byte, 4)
+	n, err := io.ReadFull(p.r, b[:1])
+	p.off += int64(n)
+	if err != nil {
+		return 0, err
+	}
+	w := 1
+	for ; w <= 4 && b[0]&(0x80>>uint(w-1)) == 0; w++ {
+	}
+	if w > 4 {
+		return 0, ErrElement
+	}
+	if err = p.read(b[1:w]); err != nil {
+		return 0, err
+	}
+	var id uint64
+	for _, c := range b[:w] {
+		id = id<<8 | uint64(c)
+	}
+	return mkvparse.ElementID(id), nil
+}
+
+//readSize returns -1 for an unknown size.
+func (p *ebmlParser) readSize() (int64, error) {
+	b := make([]byte, 8)
+	if err := p.read(b[:1]); err != nil {
+		return 0, err
+	}
+	if b[0] == 0 {
+		return 0, ErrElement
+	}
+	w := 1
+	for ; b[0]&(0x80>>uint(w-1)) == 0; w++ {
+	}
+	if err := p.read(b[1:w]); err != nil {
+		return 0, err
+	}
+	v, _, _ := vint(b[:w])
+	if v == 1<<uint(7*w)-1 {
+		return -1, nil
+	}
+	return int64(v), nil
+}
+
+func (p *ebmlParser) skip(size int64) (err error) {
+	if s, ok := p.r.(io.Seeker); ok {
+		_, err = s.Seek(size, io.SeekCurrent)
+	} else {
+		_, err = io.CopyN(ioutil.Discard, p.r, size)
+	}
+	p.off += size
+	return err
+}
+
+//elements reads elements until end, or for an unknown end until
+//one that is not a child of the schema level parent.
+//A nil handler skips them.
+func (p *ebmlParser) elements(end int64, lvl, parent int, h mkvparse.Handler) error {
+	for end < 0 || p.off < end {
+		id, err := p.readID()
+		if err == io.EOF && end < 0 {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if e, ok := p.s.element(id); ok && end < 0 && parent >= 0 && e.level >= 0 && e.level <= parent {
+			p.next, p.hasNext = id, true
+			return nil
+		}
+		if err = p.element(id, lvl, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *ebmlParser) element(id mkvparse.ElementID, lvl int, h mkvparse.Handler) error {
+	size, err := p.readSize()
+	if err != nil {
+		return err
+	}
+	e, ok := p.s.element(id)
+	if !ok {
+		e = schemaElement{typ: "binary", level: -1}
+	}
+	info := mkvparse.ElementInfo{Offset: p.off, Size: size, Level: lvl}
+	if e.typ == "master" {
+		descend := false
+		if h != nil {
+			if descend, err = h.HandleMasterBegin(id, info); err != nil {
+				return err
+			}
+		}
+		child := h
+		if !descend {
+			child = nil
+		}
+		switch {
+		case size < 0:
+			err = p.elements(-1, lvl+1, e.level, child)
+		case descend:
+			err = p.elements(p.off+size, lvl+1, e.level, child)
+		default:
+			err = p.skip(size)
+		}
+		if err != nil || h == nil {
+			return err
+		}
+		return h.HandleMasterEnd(id, info)
+	}
+	if size < 0 {
+		return ErrElement
+	}
+	if h == nil {
+		return p.skip(size)
+	}
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, p.r, size)
+	p.off += n
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	b := buf.Bytes()
+	switch e.typ {
+	case "uinteger", "integer", "date":
+		if len(b) > 8 {
+			return ErrElement
+		}
+		v := int64(binary.BigEndian.Uint64(append(make([]byte, 8-len(b)), b...)))
+		//Sign extend
+		if e.typ != "uinteger" && len(b) > 0 && len(b) < 8 && b[0]&0x80 != 0 {
+			v -= 1 << uint(8*len(b))
+		}
+		if e.typ == "date" {
+			return h.HandleDate(id, epoch.Add(time.Duration(v)), info)
+		}
+		return h.HandleInteger(id, v, info)
+	case "float":
+		switch len(b) {
+		case 0:
+			return h.HandleFloat(id, 0, info)
+		case 4:
+			return h.HandleFloat(id, float64(math.Float32frombits(binary.BigEndian.Uint32(b))), info)
+		case 8:
+			return h.HandleFloat(id, math.Float64frombits(binary.BigEndian.Uint64(b)), info)
+		}
+		return ErrElement
+	case "string", "utf-8":
+		if i := bytes.IndexByte(b, 0); i >= 0 {
+			b = b[:i]
+		}
+		return h.HandleString(id, string(b), info)
+	}
+	return h.HandleBinary(id, b, info)
+}
+
+//docTypeHandler picks the DocType out of the EBML header.
+type docTypeHandler struct {
+	mkvparse.DefaultHandler
+	docType string
+}
+
+func (d *docTypeHandler) HandleString(id mkvparse.ElementID, value string, info mkvparse.ElementInfo) error {
+	if id == mkvparse.DocTypeElement {
+		d.docType = value
+	}
+	return nil
+}
+
+//docType reads the DocType from the EBML header at the start of r,
+//defaulting to matroska as the header does.
+func docType(r io.ReaderAt) (string, error) {
+	p := &ebmlParser{r: io.NewSectionReader(r, 0, math.MaxInt64), s: ebmlHeader}
+	id, err := p.readID()
+	if err != nil {
+		return "", err
+	}
+	if id != mkvparse.EBMLElement {
+		return "", ErrElement
+	}
+	d := &docTypeHandler{docType: "matroska"}
+	if err = p.element(id, 0, d); err != nil {
+		return "", err
+	}
+	return strings.ToLower(d.docType), nil
+}
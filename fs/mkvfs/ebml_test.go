@@ -0,0 +1,107 @@
+package mkvfs
+
+import (
+	"strings"
+	"testing"
+)
+
+//elu encodes an element of unknown size.
+func elu(id []byte, data ...[]byte) []byte {
+	b := append(append([]byte{}, id...), 0xff)
+	for _, d := range data {
+		b = append(b, d...)
+	}
+	return b
+}
+
+func ebmlHead(docType string) []byte {
+	return el([]byte{0x1a, 0x45, 0xdf, 0xa3}, el([]byte{0x42, 0x82}, []byte(docType)))
+}
+
+func TestUnknownElements(t *testing.T) {
+	b := append(ebmlHead("matroska"), el([]byte{0x18, 0x53, 0x80, 0x67},
+		el([]byte{0x15, 0x49, 0xa9, 0x66},
+			el([]byte{0x4a, 0xbc}, []byte{1, 2}),
+			el([]byte{0x7b, 0xa9}, []byte("title"))),
+		elu([]byte{0x1f, 0x43, 0xb6, 0x75},
+			el([]byte{0xe7}, []byte{0}),
+			el([]byte{0xa3}, []byte{0x81, 0x00, 0x00, 0x80}, []byte("aa"))),
+		elu([]byte{0x1f, 0x43, 0xb6, 0x75},
+			el([]byte{0xe7}, []byte{1}),
+			el([]byte{0xa3}, []byte{0x81, 0x00, 0x00, 0x80}, []byte("bb"))))...)
+	m, done := loadBytes(t, b)
+	defer done()
+	tests := []struct {
+		fpath  string
+		expect string
+	}{
+		{"contents/Segment/Info/0x4ABC/type", "binary"},
+		{"contents/Segment/Info/0x4ABC/value", "0102"},
+		{"contents/Segment/Info/Title/value", "title"},
+		{"contents/Segment/Cluster2/SimpleBlock/size", "6"},
+		{"tracks/1/stream", "aabb"},
+	}
+	for _, test := range tests {
+		if s := readPath(t, m, test.fpath); s != test.expect {
+			t.Fatalf("expected %q got %q for %s", test.expect, s, test.fpath)
+		}
+	}
+}
+
+const testSchema = `<?xml version="1.0" encoding="utf-8"?>
+<EBMLSchema xmlns="urn:ietf:rfc:8794" docType="test" version="1">
+  <element name="Root" path="\Root" id="0x10000001" type="master"/>
+  <element name="Name" path="\Root\Name" id="0x4100" type="utf-8"/>
+  <element name="Count" path="\Root\Count" id="0x86" type="uinteger"/>
+</EBMLSchema>
+`
+
+func TestSchema(t *testing.T) {
+	s, err := LoadSchema(strings.NewReader(testSchema))
+	if err != nil {
+		t.Fatal("error loading schema:", err)
+	}
+	b := append(ebmlHead("test"), el([]byte{0x10, 0x00, 0x00, 0x01},
+		el([]byte{0x41, 0x00}, []byte("hi")),
+		el([]byte{0x86}, []byte{5}),
+		el([]byte{0x4a, 0xbc}, []byte{1}))...)
+	m, done := loadBytes(t, b, s)
+	defer done()
+	tests := []struct {
+		fpath  string
+		expect string
+	}{
+		{"contents/EBML/DocType/value", "test"},
+		{"contents/Root/Name/value", "hi"},
+		{"contents/Root/Count/value", "5"},
+		{"contents/Root/0x4ABC/type", "binary"},
+	}
+	for _, test := range tests {
+		if s := readPath(t, m, test.fpath); s != test.expect {
+			t.Fatalf("expected %q got %q for %s", test.expect, s, test.fpath)
+		}
+	}
+	if !strings.Contains(readPath(t, m, "status"), "doctype test\n") {
+		t.Fatal("doctype missing from status")
+	}
+	if _, err = LoadSchema(strings.NewReader(`<EBMLSchema docType="x"><element name="A" id="zz" type="master"/></EBMLSchema>`)); err != ErrSchema {
+		t.Fatalf("expected %v got %v", ErrSchema, err)
+	}
+}
+
+func TestWebM(t *testing.T) {
+	b := append(ebmlHead("webm"), el([]byte{0x18, 0x53, 0x80, 0x67},
+		el([]byte{0x16, 0x54, 0xae, 0x6b},
+			el([]byte{0xae},
+				el([]byte{0xd7}, []byte{1}),
+				el([]byte{0x86}, []byte("A_AAC")))),
+		el([]byte{0x19, 0x41, 0xa4, 0x69}))...)
+	m, done := loadBytes(t, b)
+	defer done()
+	status := readPath(t, m, "status")
+	for _, line := range []string{"doctype webm", "webm: codec A_AAC not allowed", "webm: Attachments not allowed"} {
+		if !strings.Contains(status, line+"\n") {
+			t.Fatalf("expected %q in status %q", line, status)
+		}
+	}
+}
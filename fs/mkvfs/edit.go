@@ -0,0 +1,459 @@
+package mkvfs
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/majiru/ffs/pkg/fsutil"
+	"github.com/majiru/ffs/pkg/synthfile"
+	"github.com/remko/go-mkvparse"
+)
+
+var ErrNoHeader = errors.New("mkvfs: can not find element header")
+var ErrTooLarge = errors.New("mkvfs: size does not fit its field")
+var ErrNotEditing = errors.New("mkvfs: editing is off, write edit on to ctl")
+var ErrFlag = errors.New("mkvfs: flags are 0 or 1")
+
+//copyError is returned by writes to value files that no longer fit,
+//the loaded file is left as it was and the edit is only in the copy named.
+type copyError string
+
+func (c copyError) Error() string {
+	return "mkvfs: value does not fit, edited copy written to " + string(c)
+}
+
+//Kinds of editable values.
+const (
+	editString = iota + 1
+	editFlag
+)
+
+//editable lists the elements whose value files can be written:
+//titles, names, languages, tags and track flags.
+var editable = map[mkvparse.ElementID]int{
+	mkvparse.TitleElement:        editString,
+	mkvparse.NameElement:         editString,
+	mkvparse.LanguageElement:     editString,
+	mkvparse.LanguageIETFElement: editString,
+	mkvparse.ChapStringElement:   editString,
+	mkvparse.TagNameElement:      editString,
+	mkvparse.TagStringElement:    editString,
+	mkvparse.TagLanguageElement:  editString,
+	mkvparse.FlagDefaultElement:  editFlag,
+	mkvparse.FlagForcedElement:   editFlag,
+	mkvparse.FlagEnabledElement:  editFlag,
+}
+
+//elem is where an element's data is in the file.
+type elem struct {
+	id   mkvparse.ElementID
+	off  int64
+	size int64
+}
+
+//position is a SeekPosition or CueClusterPosition,
+//value is counted from the start of the Segment's data.
+type position struct {
+	elem
+	value   int64
+	segment int64
+}
+
+//positions collects the positions in a file, which have to
+//be moved when the elements they point past change size.
+type positions struct {
+	mkvparse.DefaultHandler
+	segment int64
+	list    []position
+}
+
+func (p *positions) HandleMasterBegin(id mkvparse.ElementID, info mkvparse.ElementInfo) (bool, error) {
+	if id == mkvparse.SegmentElement {
+		p.segment = info.Offset
+	}
+	return true, nil
+}
+
+func (p *positions) HandleInteger(id mkvparse.ElementID, value int64, info mkvparse.ElementInfo) error {
+	if id == mkvparse.SeekPositionElement || id == mkvparse.CueClusterPositionElement {
+		p.list = append(p.list, position{elem{id, info.Offset, info.Size}, value, p.segment})
+	}
+	return nil
+}
+
+//setEditing allows or refuses writes to the value files of m,
+//they are only writable while the session's ctl has editing on.
+func (m *mkv) setEditing(on bool) {
+	m.Lock()
+	defer m.Unlock()
+	m.editing = on
+	for _, s := range m.values {
+		s.Chmod(valueMode(on))
+	}
+}
+
+func (m *mkv) canEdit() bool {
+	m.Lock()
+	defer m.Unlock()
+	return m.editing
+}
+
+func valueMode(editing bool) os.FileMode {
+	if editing {
+		return 0644
+	}
+	return 0444
+}
+
+//editFile makes f the value file of the last element in path,
+//writable while editing is on. master is the directory of its master.
+//A write replaces the value, the file is changed in place if the element
+//still fits, otherwise an edited copy is written next to it and the write
+//fails with an error naming the copy.
+func (m *mkv) editFile(path []elem, master *fsutil.Dir, f *fsutil.File) os.FileInfo {
+	e := path[len(path)-1]
+	var mu sync.Mutex
+	open := make(map[*synthfile.Handle]bool)
+	b := make([]byte, f.Size())
+	f.ReadAt(b, 0)
+	cur := string(b)
+	//set shows v in f and h, opening f with O_TRUNC empties it
+	//so every handle reads its own copy
+	set := func(h *synthfile.Handle, v string) {
+		f.Truncate(0)
+		f.WriteAt([]byte(v), 0)
+		h.Content = fsutil.CreateFile([]byte(v), 0444, "value")
+	}
+	synth := synthfile.WrapFile(f, synthfile.Handler{
+		OnOpen: func(h *synthfile.Handle) error {
+			mu.Lock()
+			defer mu.Unlock()
+			open[h] = true
+			set(h, cur)
+			return nil
+		},
+		OnWrite: func(h *synthfile.Handle, b []byte, off int64) (int, error) {
+			if !m.canEdit() {
+				return 0, ErrNotEditing
+			}
+			value := strings.TrimRight(string(b), "\n")
+			data, err := encodeValue(e.id, value)
+			if err != nil {
+				return 0, err
+			}
+			inplace, err := m.edit(path, master, data)
+			if err != nil {
+				return 0, err
+			}
+			if inplace {
+				mu.Lock()
+				cur = value
+				for o := range open {
+					set(o, cur)
+				}
+				mu.Unlock()
+			}
+			return len(b), nil
+		},
+		OnTruncate: func(h *synthfile.Handle, size int64) error {
+			return nil
+		},
+		OnClose: func(h *synthfile.Handle) error {
+			mu.Lock()
+			delete(open, h)
+			mu.Unlock()
+			return nil
+		},
+	})
+	m.Lock()
+	synth.Content.Stats.Chmod(valueMode(m.editing))
+	m.values = append(m.values, synth.Content.Stats)
+	m.Unlock()
+	return synth.Content.Stats
+}
+
+//refresh parses the children of the master at the end of path from r
+//again into its directory dir, after one of them was changed in place,
+//so their sizes, raw files and the Void after the change show what is
+//now on disk. The children are parsed into a new directory first to keep
+//their names. It is called with m.d locked, which keeps edits in turn.
+func (m *mkv) refresh(r io.ReaderAt, path []elem, dir *fsutil.Dir) error {
+	if len(path) == 0 || path[len(path)-1].size < 0 {
+		return nil
+	}
+	master := path[len(path)-1]
+	p := NewTreeParser(fsutil.CreateDir(dir.Stats.Name()))
+	p.Schema = m.schema
+	p.src = m
+	p.edit = m.editFile
+	p.elems = append([]elem{}, path...)
+	if err := parseEBML(io.NewSectionReader(r, master.off, master.size), m.schema, &shifted{p, master.off}); err != nil {
+		return err
+	}
+	//The end of an Info is not in its children
+	if p.duration != nil {
+		p.duration.Truncate(0)
+		p.duration.WriteAt([]byte(formatTimecode(p.rawdur, p.scale)), 0)
+	}
+	for _, fi := range dir.Copy() {
+		if fi.IsDir() {
+			dir.Remove(fi.Name())
+		}
+	}
+	dir.Append(p.Root.Copy()...)
+	return nil
+}
+
+func encodeValue(id mkvparse.ElementID, value string) ([]byte, error) {
+	if editable[id] == editString {
+		return []byte(value), nil
+	}
+	v, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if editable[id] == editFlag && v > 1 {
+		return nil, ErrFlag
+	}
+	return uintBytes(v, 0), nil
+}
+
+//uintBytes encodes v big endian in at least min bytes.
+func uintBytes(v uint64, min int) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	for len(b) > 1 && len(b) > min && b[0] == 0 {
+		b = b[1:]
+	}
+	return b
+}
+
+//putVint encodes v as an EBML variable length integer of width w.
+func putVint(v uint64, w int) ([]byte, error) {
+	if w < 1 || w > 8 || v >= 1<<uint(7*w)-1 {
+		return nil, ErrTooLarge
+	}
+	b := uintBytes(v, w)
+	b[0] |= 0x80 >> uint(w-1)
+	return b, nil
+}
+
+func vintWidth(v uint64) int {
+	w := 1
+	for ; w < 8 && v >= 1<<uint(7*w)-1; w++ {
+	}
+	return w
+}
+
+//header returns where the header of e starts and the width of its size.
+func header(r io.ReaderAt, e elem) (int64, int, error) {
+	id := uintBytes(uint64(e.id), 0)
+	start := e.off - int64(len(id)) - 8
+	if start < 0 {
+		start = 0
+	}
+	b := make([]byte, e.off-start)
+	if _, err := r.ReadAt(b, start); err != nil {
+		return 0, 0, err
+	}
+	for w := 1; w <= 8 && w+len(id) <= len(b); w++ {
+		i := len(b) - w
+		v, n, err := vint(b[i:])
+		if err != nil || n != w || int64(v) != e.size || string(b[i-len(id):i]) != string(id) {
+			continue
+		}
+		return e.off - int64(w+len(id)), w, nil
+	}
+	return 0, 0, ErrNoHeader
+}
+
+//edit replaces the data of the last element in path with data. It reports
+//whether the loaded file was changed in place, then master, the directory
+//of its master, is refreshed. If the element no longer fits a copy
+//is written with the sizes and positions after it moved.
+func (m *mkv) edit(path []elem, master *fsutil.Dir, data []byte) (bool, error) {
+	m.d.Lock()
+	defer m.d.Unlock()
+	f := m.d.f
+	if f == nil {
+		return false, ErrNotLoaded
+	}
+	e := path[len(path)-1]
+	start, w, err := header(f, e)
+	if err != nil {
+		return false, err
+	}
+	idlen := e.off - start - int64(w)
+	if b, size, err := place(f, e, w, data); err == nil {
+		out, err := os.OpenFile(m.path, os.O_WRONLY, 0)
+		if err != nil {
+			return false, err
+		}
+		defer out.Close()
+		if _, err = out.WriteAt(b, start+idlen); err != nil {
+			return false, err
+		}
+		path[len(path)-1].size = size
+		return true, m.refresh(f, path[:len(path)-1], master)
+	}
+	name, err := m.rewrite(f, path, start, idlen, data)
+	if err != nil {
+		return false, err
+	}
+	return false, copyError(name)
+}
+
+//place returns the size and data of e when data replaces it in place,
+//followed by a Void over what is left, and the new size of e.
+//A Void directly after e is taken up as well.
+//A single spare byte pads the value instead.
+func place(f io.ReaderAt, e elem, w int, data []byte) ([]byte, int64, error) {
+	avail := e.size
+	b := make([]byte, 9)
+	if n, _ := f.ReadAt(b, e.off+e.size); n > 1 && b[0] == byte(mkvparse.VoidElement) {
+		if v, vn, err := vint(b[1:n]); err == nil {
+			avail += 1 + int64(vn) + int64(v)
+		}
+	}
+	rest := avail - int64(len(data))
+	switch {
+	case rest < 0:
+		return nil, 0, ErrTooLarge
+	case rest == 1 && editable[e.id] == editString:
+		data = append(data, 0)
+	case rest == 1 && len(data) < 8:
+		data = append([]byte{0}, data...)
+	case rest == 1:
+		return nil, 0, ErrTooLarge
+	}
+	size, err := putVint(uint64(len(data)), w)
+	if err != nil {
+		return nil, 0, err
+	}
+	b = append(size, data...)
+	if rest = avail - int64(len(data)); rest >= 2 {
+		vw := 1
+		for rest-1-int64(vw) >= 1<<uint(7*vw)-1 {
+			vw++
+		}
+		vsize, _ := putVint(uint64(rest-1-int64(vw)), vw)
+		b = append(b, byte(mkvparse.VoidElement))
+		b = append(b, vsize...)
+		b = append(b, make([]byte, rest-1-int64(vw))...)
+	}
+	return b, int64(len(data)), nil
+}
+
+//patch is a change to the bytes at off in the original file.
+type patch struct {
+	off int64
+	b   []byte
+}
+
+//rewrite writes a copy of the file with the data of the last element
+//in path replaced. The sizes of its parents grow by the difference and
+//positions pointing after it are moved, each keeping the width it had.
+//The copy is named after the loaded file, with .edited before its extension,
+//its name is returned.
+func (m *mkv) rewrite(f io.ReaderAt, path []elem, start, idlen int64, data []byte) (string, error) {
+	e := path[len(path)-1]
+	w := vintWidth(uint64(len(data)))
+	size, _ := putVint(uint64(len(data)), w)
+	head := make([]byte, idlen)
+	if _, err := f.ReadAt(head, start); err != nil {
+		return "", err
+	}
+	elembytes := append(append(head, size...), data...)
+	delta := int64(len(elembytes)) - (e.off + e.size - start)
+
+	var patches []patch
+	for _, a := range path[:len(path)-1] {
+		if a.size < 0 {
+			continue
+		}
+		_, aw, err := header(f, a)
+		if err != nil {
+			return "", err
+		}
+		b, err := putVint(uint64(a.size+delta), aw)
+		if err != nil {
+			return "", err
+		}
+		patches = append(patches, patch{a.off - int64(aw), b})
+	}
+	for _, p := range m.seeks.list {
+		if p.segment+p.value <= start {
+			continue
+		}
+		b := uintBytes(uint64(p.value+delta), int(p.size))
+		if int64(len(b)) != p.size {
+			return "", ErrTooLarge
+		}
+		patches = append(patches, patch{p.off, b})
+	}
+	sort.Slice(patches, func(i, j int) bool { return patches[i].off < patches[j].off })
+
+	out, name, err := createEdited(m.path)
+	if err != nil {
+		return "", err
+	}
+	err = copyPatched(out, f, 0, start, patches)
+	if err == nil {
+		_, err = out.Write(elembytes)
+	}
+	if err == nil {
+		err = copyPatched(out, f, e.off+e.size, m.size, patches)
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(name)
+		return "", err
+	}
+	m.Lock()
+	m.edits = append(m.edits, "rewrote to "+name)
+	m.Unlock()
+	return name, nil
+}
+
+//createEdited creates the first free name for an edited copy of fpath.
+func createEdited(fpath string) (*os.File, string, error) {
+	ext := filepath.Ext(fpath)
+	base := strings.TrimSuffix(fpath, ext) + ".edited"
+	for i := 1; ; i++ {
+		name := base + ext
+		if i > 1 {
+			name = base + strconv.Itoa(i) + ext
+		}
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if !os.IsExist(err) {
+			return f, name, err
+		}
+	}
+}
+
+//copyPatched copies from to to of r to w, with the patches inside it applied.
+func copyPatched(w io.Writer, r io.ReaderAt, from, to int64, patches []patch) error {
+	for _, p := range patches {
+		if p.off < from || p.off >= to {
+			continue
+		}
+		if _, err := io.Copy(w, io.NewSectionReader(r, from, p.off-from)); err != nil {
+			return err
+		}
+		if _, err := w.Write(p.b); err != nil {
+			return err
+		}
+		from = p.off + int64(len(p.b))
+	}
+	_, err := io.Copy(w, io.NewSectionReader(r, from, to-from))
+	return err
+}
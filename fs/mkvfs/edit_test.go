@@ -0,0 +1,121 @@
+package mkvfs
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/majiru/ffs/pkg/synthfile"
+)
+
+func editMKV() []byte {
+	return el([]byte{0x18, 0x53, 0x80, 0x67},
+		el([]byte{0x15, 0x49, 0xa9, 0x66}, el([]byte{0x7b, 0xa9}, []byte("abcdef"))),
+		el([]byte{0x16, 0x54, 0xae, 0x6b},
+			el([]byte{0xae},
+				el([]byte{0xd7}, []byte{1}),
+				el([]byte{0x88}, []byte{1}))))
+}
+
+func writeValue(t *testing.T, m *mkv, fpath, value string) error {
+	fi, err := m.dir.Walk(fpath)
+	if err != nil {
+		t.Fatal("error walking to", fpath, err)
+	}
+	f, ok := fi.Sys().(*synthfile.File)
+	if !ok {
+		t.Fatal(fpath, "is not editable")
+	}
+	h, err := f.Open(os.O_WRONLY | os.O_TRUNC)
+	if err != nil {
+		t.Fatal("error opening", fpath, err)
+	}
+	defer h.Close()
+	_, err = h.Write([]byte(value))
+	return err
+}
+
+func TestEdit(t *testing.T) {
+	m, done := loadBytes(t, editMKV())
+	defer done()
+	title := "contents/Segment/Info/Title/value"
+	flag := "contents/Segment/Tracks/TrackEntry/FlagDefault/value"
+	if err := writeValue(t, m, title, "abc\n"); err != ErrNotEditing {
+		t.Fatalf("expected %v got %v before editing is on", ErrNotEditing, err)
+	}
+	if fi, _ := m.dir.Walk(title); fi.Mode() != 0444 {
+		t.Fatalf("expected mode %v got %v before editing is on", os.FileMode(0444), fi.Mode())
+	}
+	m.setEditing(true)
+	fi, _ := m.dir.Walk(title)
+	if fi.Mode() != 0644 {
+		t.Fatalf("expected mode %v got %v with editing on", os.FileMode(0644), fi.Mode())
+	}
+	other, err := fi.Sys().(*synthfile.File).Open(os.O_RDONLY)
+	if err != nil {
+		t.Fatal("error opening title:", err)
+	}
+	defer other.Close()
+	if err := writeValue(t, m, title, "abc\n"); err != nil {
+		t.Fatal("error editing title:", err)
+	}
+	if b, _ := ioutil.ReadAll(other); string(b) != "abc" {
+		t.Fatalf("expected %q got %q from a handle opened before the edit", "abc", b)
+	}
+	//The loaded tree follows the edit
+	for fpath, expect := range map[string]string{
+		"contents/Segment/Info/Title/size": "3",
+		"contents/Segment/Info/Void/size":  "1",
+	} {
+		if s := readPath(t, m, fpath); s != expect {
+			t.Fatalf("expected %q got %q for %s", expect, s, fpath)
+		}
+	}
+	if fi, err = m.dir.Walk("contents/Segment/Info/Title/raw"); err != nil || fi.Size() != 3 {
+		t.Fatalf("expected raw of size 3 got %v %v", fi, err)
+	}
+	if err := writeValue(t, m, flag, "2"); err != ErrFlag {
+		t.Fatalf("expected %v got %v for flag of 2", ErrFlag, err)
+	}
+	if err := writeValue(t, m, flag, "0"); err != nil {
+		t.Fatal("error editing flag:", err)
+	}
+	if s := readPath(t, m, title); s != "abc" {
+		t.Fatalf("expected %q got %q", "abc", s)
+	}
+	r := openParsed(t, m.path)
+	for fpath, expect := range map[string]string{
+		title:                             "abc",
+		"contents/Segment/Info/Void/size": "1",
+		flag:                              "0",
+	} {
+		if s := readPath(t, r, fpath); s != expect {
+			t.Fatalf("expected %q got %q for %s", expect, s, fpath)
+		}
+	}
+	r.close()
+
+	//A value that does not fit fails, naming the copy it went to
+	err = writeValue(t, m, title, "a much longer title")
+	copied, ok := err.(copyError)
+	if !ok {
+		t.Fatalf("expected the copy to be named, got %v", err)
+	}
+	out := string(copied)
+	if s := readPath(t, m, title); s != "abc" {
+		t.Fatalf("loaded file changed by rewrite, title is %q", s)
+	}
+	if status := readPath(t, m, "status"); !strings.Contains(status, "edit rewrote to "+out+"\n") {
+		t.Fatalf("no rewrite in status %q", status)
+	}
+	defer os.Remove(out)
+	r = openParsed(t, out)
+	defer r.close()
+	if s := readPath(t, r, title); s != "a much longer title" {
+		t.Fatalf("expected %q got %q", "a much longer title", s)
+	}
+	if s := readPath(t, r, "contents/Segment/Tracks/TrackEntry/FlagDefault/value"); s != "0" {
+		t.Fatalf("expected flag to follow the edit, got %q", s)
+	}
+}
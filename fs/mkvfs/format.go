@@ -0,0 +1,109 @@
+package mkvfs
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/remko/go-mkvparse"
+)
+
+//codecs names the codecs of the common CodecIDs.
+var codecs = map[string]string{
+	"V_MPEG4/ISO/AVC":  "H.264",
+	"V_MPEGH/ISO/HEVC": "H.265",
+	"V_MPEG4/ISO/SP":   "MPEG-4 Part 2",
+	"V_MPEG4/ISO/ASP":  "MPEG-4 Part 2",
+	"V_MPEG2":          "MPEG-2",
+	"V_MPEG1":          "MPEG-1",
+	"V_VP8":            "VP8",
+	"V_VP9":            "VP9",
+	"V_AV1":            "AV1",
+	"V_THEORA":         "Theora",
+	"V_MS/VFW/FOURCC":  "Video for Windows",
+	"A_AAC":            "AAC",
+	"A_AC3":            "Dolby Digital",
+	"A_EAC3":           "Dolby Digital Plus",
+	"A_TRUEHD":         "Dolby TrueHD",
+	"A_DTS":            "DTS",
+	"A_OPUS":           "Opus",
+	"A_VORBIS":         "Vorbis",
+	"A_FLAC":           "FLAC",
+	"A_ALAC":           "Apple Lossless",
+	"A_MPEG/L3":        "MP3",
+	"A_MPEG/L2":        "MP2",
+	"A_PCM/INT/LIT":    "PCM",
+	"A_PCM/INT/BIG":    "PCM",
+	"A_PCM/FLOAT/IEEE": "PCM",
+	"S_TEXT/UTF8":      "SubRip",
+	"S_TEXT/SSA":       "SubStation Alpha",
+	"S_TEXT/ASS":       "Advanced SubStation Alpha",
+	"S_TEXT/WEBVTT":    "WebVTT",
+	"S_HDMV/PGS":       "PGS",
+	"S_VOBSUB":         "VobSub",
+}
+
+var trackTypes = map[int64]string{
+	1:    "video",
+	2:    "audio",
+	3:    "complex",
+	0x10: "logo",
+	0x11: "subtitle",
+	0x12: "buttons",
+	0x20: "control",
+}
+
+func formatString(id mkvparse.ElementID, value string) string {
+	if id == mkvparse.CodecIDElement {
+		if name, ok := codecs[value]; ok {
+			return value + " (" + name + ")"
+		}
+	}
+	return value
+}
+
+//formatDuration appends a readable form of ns nanoseconds to raw.
+func formatDuration(raw string, ns float64) string {
+	return raw + " (" + time.Duration(ns).String() + ")"
+}
+
+//formatClock formats ns nanoseconds as hh:mm:ss.mmm.
+func formatClock(ns int64) string {
+	d := time.Duration(ns)
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", int64(d.Hours()), int64(d.Minutes())%60, int64(d.Seconds())%60, d.Milliseconds()%1000)
+}
+
+//formatTimecode formats v, counted in units of scale nanoseconds.
+func formatTimecode(v float64, scale int64) string {
+	return formatDuration(strconv.FormatFloat(v, 'f', -1, 64), v*float64(scale))
+}
+
+func formatInteger(id mkvparse.ElementID, value int64, scale int64) string {
+	raw := strconv.FormatInt(value, 10)
+	switch id {
+	//Counted in nanoseconds
+	case mkvparse.TimecodeScaleElement, mkvparse.DefaultDurationElement,
+		mkvparse.DefaultDecodedFieldDurationElement,
+		mkvparse.ChapterTimeStartElement, mkvparse.ChapterTimeEndElement:
+		return formatDuration(raw, float64(value))
+	//Counted in TimecodeScale
+	case mkvparse.TimecodeElement, mkvparse.CueTimeElement,
+		mkvparse.CueDurationElement, mkvparse.BlockDurationElement:
+		return formatDuration(raw, float64(value)*float64(scale))
+	case mkvparse.TrackTypeElement:
+		if name, ok := trackTypes[value]; ok {
+			return raw + " (" + name + ")"
+		}
+	}
+	return raw
+}
+
+//formatBinary hex encodes short values, longer ones such
+//as blocks are only described by their length.
+func formatBinary(value []byte) string {
+	if len(value) > 32 {
+		return "[" + strconv.Itoa(len(value)) + " bytes]"
+	}
+	return hex.EncodeToString(value)
+}
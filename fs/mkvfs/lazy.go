@@ -0,0 +1,114 @@
+package mkvfs
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/majiru/ffs/pkg/fsutil"
+	"github.com/remko/go-mkvparse"
+)
+
+//cluster is a Cluster whose children are only parsed
+//once its directory is first used.
+type cluster struct {
+	once sync.Once
+	dir  *fsutil.Dir
+	off  int64
+	size int64
+	//scale is the TimecodeScale in effect for the cluster
+	scale int64
+	err   error
+}
+
+//load parses the children of c into its directory.
+func (c *cluster) load(m *mkv) {
+	c.once.Do(func() {
+		r, err := m.readerAt()
+		if err != nil {
+			c.err = err
+			return
+		}
+		p := NewTreeParser(c.dir)
+		p.Schema = m.schema
+		p.src = m
+		p.scale = c.scale
+		c.err = parseEBML(io.NewSectionReader(r, c.off, c.size), m.schema, &shifted{p, c.off})
+		atomic.AddInt64(&m.loaded, 1)
+	})
+}
+
+//skipClusters keeps the parser from descending into Clusters of known size,
+//the handler is still told where they begin and end.
+type skipClusters struct {
+	mkvparse.Handler
+}
+
+func (s skipClusters) HandleMasterBegin(id mkvparse.ElementID, info mkvparse.ElementInfo) (bool, error) {
+	descend, err := s.Handler.HandleMasterBegin(id, info)
+	if id == mkvparse.ClusterElement && info.Size >= 0 {
+		return false, err
+	}
+	return descend, err
+}
+
+//shifted moves the offsets given to a handler by base,
+//for parsing a section of the file.
+type shifted struct {
+	h    mkvparse.Handler
+	base int64
+}
+
+func (s *shifted) info(info mkvparse.ElementInfo) mkvparse.ElementInfo {
+	info.Offset += s.base
+	return info
+}
+
+func (s *shifted) HandleMasterBegin(id mkvparse.ElementID, info mkvparse.ElementInfo) (bool, error) {
+	return s.h.HandleMasterBegin(id, s.info(info))
+}
+
+func (s *shifted) HandleMasterEnd(id mkvparse.ElementID, info mkvparse.ElementInfo) error {
+	return s.h.HandleMasterEnd(id, s.info(info))
+}
+
+func (s *shifted) HandleString(id mkvparse.ElementID, value string, info mkvparse.ElementInfo) error {
+	return s.h.HandleString(id, value, s.info(info))
+}
+
+func (s *shifted) HandleInteger(id mkvparse.ElementID, value int64, info mkvparse.ElementInfo) error {
+	return s.h.HandleInteger(id, value, s.info(info))
+}
+
+func (s *shifted) HandleFloat(id mkvparse.ElementID, value float64, info mkvparse.ElementInfo) error {
+	return s.h.HandleFloat(id, value, s.info(info))
+}
+
+func (s *shifted) HandleDate(id mkvparse.ElementID, value time.Time, info mkvparse.ElementInfo) error {
+	return s.h.HandleDate(id, value, s.info(info))
+}
+
+func (s *shifted) HandleBinary(id mkvparse.ElementID, value []byte, info mkvparse.ElementInfo) error {
+	return s.h.HandleBinary(id, value, s.info(info))
+}
+
+//progress counts how far into the file the parser is.
+type progress struct {
+	io.ReadSeeker
+	pos *int64
+}
+
+func (p *progress) Read(b []byte) (int, error) {
+	n, err := p.ReadSeeker.Read(b)
+	atomic.AddInt64(p.pos, int64(n))
+	return n, err
+}
+
+func (p *progress) Seek(offset int64, whence int) (int64, error) {
+	pos, err := p.ReadSeeker.Seek(offset, whence)
+	if err == nil {
+		atomic.StoreInt64(p.pos, pos)
+	}
+	return pos, err
+}
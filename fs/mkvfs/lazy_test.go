@@ -0,0 +1,38 @@
+package mkvfs
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLazyCluster(t *testing.T) {
+	b := trackMKV()
+	m, done := loadBytes(t, b)
+	defer done()
+	dir, err := m.dir.WalkForDir("contents/Segment/Cluster")
+	if err != nil {
+		t.Fatal("error walking to cluster:", err)
+	}
+	if _, err = dir.Find("SimpleBlock"); err == nil {
+		t.Fatal("cluster parsed before use")
+	}
+	status := readPath(t, m, "status")
+	if !strings.HasPrefix(status, "ok\n") || !strings.Contains(status, "0/1 clusters parsed") || !strings.Contains(status, "tracks pending") {
+		t.Fatalf("unexpected status %q", status)
+	}
+	offset := readPath(t, m, "contents/Segment/Cluster/SimpleBlock/offset")
+	expect := bytes.Index(b, []byte{0x81, 0x00, 0x14, 0x80})
+	if offset != strconv.Itoa(expect) {
+		t.Fatalf("expected offset %d got %s", expect, offset)
+	}
+	if s := readPath(t, m, "contents/Segment/Cluster/BlockGroup/Block/raw"); s != "\x82\x00\x00\x00Hi" {
+		t.Fatalf("unexpected raw block %q", s)
+	}
+	readPath(t, m, "tracks/1/stream")
+	status = readPath(t, m, "status")
+	if !strings.Contains(status, "1/1 clusters parsed") || !strings.Contains(status, "tracks ok") {
+		t.Fatalf("unexpected status %q", status)
+	}
+}
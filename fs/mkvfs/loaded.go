@@ -0,0 +1,262 @@
+package mkvfs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/majiru/ffs/pkg/fsutil"
+	"github.com/majiru/ffs/pkg/synthfile"
+	"github.com/remko/go-mkvparse"
+)
+
+//mkv is a file loaded into a session.
+//Its directory holds the data file for decoding blocks,
+//the parsed tree of elements in contents, its demuxed tracks
+//and its chapters, attachments and cues.
+//
+//The children of Clusters are only parsed when their directory
+//is first used and the tracks once tracks is, which keeps large
+//files cheap to load. The status file shows how far along this is
+//and where edits that did not fit in place were written.
+type mkv struct {
+	sync.Mutex
+	name     string
+	path     string
+	size     int64
+	mtime    time.Time
+	status   string
+	dir      *fsutil.Dir
+	contents *fsutil.Dir
+	d        *Decoder
+	//pos is how far the first pass has read, loaded
+	//counts the clusters parsed since, both are atomic
+	pos      int64
+	loaded   int64
+	clusters map[string]*cluster
+	order    []*cluster
+	parsed   chan struct{}
+	demux    *demuxer
+	tracks   sync.Once
+	tstatus  string
+	//seeks holds the positions to move when rewriting the file
+	seeks *positions
+	edits []string
+	//editing allows writes to the value files in values
+	editing bool
+	values  []*fsutil.Stat
+	//schemas holds the schemas to choose from by DocType,
+	//schema is the one chosen
+	schemas  map[string]*Schema
+	schema   *Schema
+	doctype  string
+	restrict *restrictions
+}
+
+//openMKV opens fpath for loading as name, it is not parsed yet.
+func openMKV(fpath, name string) (*mkv, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	m := &mkv{
+		name:     name,
+		path:     fpath,
+		size:     fi.Size(),
+		mtime:    fi.ModTime(),
+		status:   "parsing",
+		contents: fsutil.CreateDir("contents"),
+		d:        NewDecoder(),
+		clusters: make(map[string]*cluster),
+		parsed:   make(chan struct{}),
+		tstatus:  "pending",
+		schemas:  map[string]*Schema{Matroska.DocType: Matroska, WebM.DocType: WebM},
+		schema:   Matroska,
+	}
+	m.d.f = f
+	m.demux = newDemuxer(m)
+	m.seeks = &positions{}
+	status := synthfile.CreateFile([]byte{}, 0444, "status", synthfile.Handler{
+		OnOpen: func(h *synthfile.Handle) error {
+			h.Content = fsutil.CreateFile([]byte(m.progress()), 0444, "status")
+			return nil
+		},
+	})
+	m.dir = fsutil.CreateDir(name, m.d.Data.Content.Stats, status.Content.Stats,
+		m.contents.Stats, fsutil.CreateSortedDir("tracks").Stats,
+		fsutil.CreateDir("chapters").Stats, fsutil.CreateDir("attachments").Stats,
+		fsutil.CreateSortedDir("cues").Stats)
+	return m, nil
+}
+
+//parse makes the first pass over the file, building the tree of
+//elements outside Clusters and the chapters, attachments and cues.
+//Documents that are not Matroska or WebM only get the tree,
+//named by the schema for their DocType if there is one.
+//A file that fails to parse keeps what was read before the error.
+func (m *mkv) parse() error {
+	defer close(m.parsed)
+	m.d.RLock()
+	f := m.d.f
+	m.d.RUnlock()
+	if f == nil {
+		return ErrNotLoaded
+	}
+	dt, err := docType(f)
+	if err != nil {
+		dt = Matroska.DocType
+	}
+	m.Lock()
+	m.doctype = dt
+	s, ok := m.schemas[dt]
+	if !ok {
+		s = ebmlHeader
+	}
+	m.schema = s
+	m.Unlock()
+	p := NewTreeParser(m.contents)
+	p.Schema = s
+	p.src = m
+	var (
+		h mkvparse.Handler = p
+		v *views
+	)
+	if s == Matroska || s == WebM {
+		p.lazy = m.addCluster
+		p.edit = m.editFile
+		v = newViews(m)
+		m.restrict = newRestrictions(s)
+		h = skipClusters{mkvparse.NewHandlerChain(p, m.demux, v, m.seeks, m.restrict)}
+	}
+	err = parseEBML(&progress{f, &m.pos}, s, h)
+	if v != nil {
+		for _, dir := range v.dirs() {
+			m.dir.Replace(dir.Stats.Name(), dir.Stats)
+		}
+	}
+	m.Lock()
+	if err != nil {
+		m.status = "error: " + err.Error()
+	} else {
+		m.status = "ok"
+	}
+	m.Unlock()
+	return err
+}
+
+//addCluster records a Cluster left for later, fpath is its path in contents.
+func (m *mkv) addCluster(fpath string, c *cluster) {
+	m.Lock()
+	m.clusters[path.Join("contents", fpath)] = c
+	m.order = append(m.order, c)
+	m.Unlock()
+}
+
+//expand parses what is still left to parse on the way to
+//the path given by parts, which is relative to m.dir.
+func (m *mkv) expand(parts []string) {
+	if len(parts) > 0 && parts[0] == "tracks" {
+		m.loadTracks()
+		return
+	}
+	for i := range parts {
+		m.Lock()
+		c, ok := m.clusters[path.Join(parts[:i+1]...)]
+		m.Unlock()
+		if ok {
+			c.load(m)
+		}
+	}
+}
+
+//loadTracks scans every Cluster for the frames of the tracks,
+//once the first pass has found them all.
+func (m *mkv) loadTracks() {
+	m.tracks.Do(func() {
+		<-m.parsed
+		err := m.scan()
+		tracks, terr := m.demux.dir()
+		if err == nil {
+			err = terr
+		}
+		m.dir.Replace("tracks", tracks.Stats)
+		m.Lock()
+		if err != nil {
+			m.tstatus = "error: " + err.Error()
+		} else {
+			m.tstatus = "ok"
+		}
+		m.Unlock()
+	})
+}
+
+func (m *mkv) scan() error {
+	r, err := m.readerAt()
+	if err != nil {
+		return err
+	}
+	m.Lock()
+	order := m.order
+	m.Unlock()
+	for _, c := range order {
+		m.demux.cluster = 0
+		if err = parseEBML(io.NewSectionReader(r, c.off, c.size), m.schema, &shifted{m.demux, c.off}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//progress returns the text of the status file.
+func (m *mkv) progress() string {
+	m.Lock()
+	defer m.Unlock()
+	s := fmt.Sprintf("%s\ndoctype %s\n%d/%d bytes read\n%d/%d clusters parsed\ntracks %s\n",
+		m.status, m.doctype, atomic.LoadInt64(&m.pos), m.size, atomic.LoadInt64(&m.loaded), len(m.order), m.tstatus)
+	if m.restrict != nil {
+		for _, r := range m.restrict.list() {
+			s += m.doctype + ": " + r + "\n"
+		}
+	}
+	for _, e := range m.edits {
+		s += "edit " + e + "\n"
+	}
+	return s
+}
+
+//String returns the line describing m in the session's ctl file.
+func (m *mkv) String() string {
+	m.Lock()
+	defer m.Unlock()
+	return m.name + "\t" + strconv.FormatInt(m.size, 10) + "\t" + m.status + "\t" + m.path
+}
+
+func (m *mkv) readerAt() (io.ReaderAt, error) {
+	m.d.RLock()
+	defer m.d.RUnlock()
+	if m.d.f == nil {
+		return nil, ErrNotLoaded
+	}
+	return m.d.f, nil
+}
+
+func (m *mkv) modTime() time.Time { return m.mtime }
+
+func (m *mkv) close() {
+	m.d.Lock()
+	if m.d.f != nil {
+		m.d.f.Close()
+		m.d.f = nil
+	}
+	m.d.Unlock()
+}
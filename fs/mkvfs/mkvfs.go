@@ -2,100 +2,168 @@ package mkvfs
 
 import (
 	"os"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/majiru/ffs"
-	"github.com/majiru/ffs/pkg/chanfile"
 	"github.com/majiru/ffs/pkg/fsutil"
-	"github.com/remko/go-mkvparse"
+	"github.com/majiru/ffs/pkg/synthfile"
 )
 
+//MKVfs serves the structure of matroska and other EBML files.
+//Opening /new creates a numbered session directory and reads back its number,
+//files are loaded into it through its ctl. A session is collected once it
+//has had no open files for Idle, or immediately if Idle is zero.
 type MKVfs struct {
 	*sync.RWMutex
-	root *fsutil.Dir
-	path *chanfile.File
-	p *TreeParser
-	d *Decoder
+	root     *fsutil.Dir
+	sessions map[int]*session
+	next     int
+	Idle     time.Duration
 }
 
 func NewMKVfs() *MKVfs {
-	m := &MKVfs{
+	return &MKVfs{
 		&sync.RWMutex{},
-		nil,
-		chanfile.CreateFile([]byte(""), 0644, "mkv"),
-		nil,
-		NewDecoder(),
-	}
-	contents := fsutil.CreateDir("contents")
-	m.root = fsutil.CreateDir("/", m.path.Content.Stats, contents.Stats, m.d.Block.Content.Stats, m.d.EBML.Content.Stats)
-	m.p = NewTreeParser(contents)
-	go m.pathproc()
-	return m
+		fsutil.CreateDir("/", fsutil.CreateFile([]byte{}, 0444, "new").Stats),
+		make(map[int]*session),
+		0,
+		10 * time.Minute,
+	}
+}
+
+func split(fpath string) []string {
+	return strings.Split(strings.Trim(fpath, "/"), "/")
+}
+
+//clone allocates a new session, held open by the returned file.
+func (fs *MKVfs) clone() ffs.File {
+	fs.Lock()
+	s := newSession(fs, fs.next)
+	fs.sessions[s.id] = s
+	fs.next++
+	fs.Unlock()
+	fs.root.Append(s.dir.Stats)
+	s.acquire()
+	return &ref{fsutil.CreateFile([]byte(strconv.Itoa(s.id)+"\n"), 0444, "new"), s, sync.Once{}}
 }
 
-func (fs *MKVfs) decode(fpath string) (err error) {
+//collect removes s, it is called with s locked.
+func (fs *MKVfs) collect(s *session) {
+	s.gone = true
 	fs.Lock()
-	defer fs.Unlock()
-	if fs.d.f != nil {
-		fs.d.f.Close()
+	delete(fs.sessions, s.id)
+	fs.Unlock()
+	fs.root.Remove(s.dir.Stats.Name())
+	for _, m := range s.files {
+		m.close()
 	}
-	if fs.d.f, err = os.Open(strings.TrimSuffix(fpath, "\n")); err != nil {
-		return
+}
+
+//session returns the session fpath is in.
+func (fs *MKVfs) session(fpath string) (*session, error) {
+	id, err := strconv.Atoi(split(fpath)[0])
+	if err != nil {
+		return nil, os.ErrNotExist
+	}
+	fs.RLock()
+	s, ok := fs.sessions[id]
+	fs.RUnlock()
+	if !ok {
+		return nil, os.ErrNotExist
 	}
-	err = mkvparse.Parse(fs.d.f, fs.p)
-	return
+	return s, nil
 }
 
-func (fs *MKVfs) pathproc() {
-	for {
-		m := <- fs.path.Req
-		switch(m.Type) {
-		case chanfile.Read:
-			fs.path.Recv <- chanfile.RecvMsg{chanfile.Commit, nil}
-		case chanfile.Write:
-			fs.path.Recv <- chanfile.RecvMsg{chanfile.Commit, fs.decode(string(m.Content))}
-		case chanfile.Trunc:
-			fs.path.Recv <- chanfile.RecvMsg{chanfile.Commit, nil}
-		case chanfile.Close:
-			fs.path.Recv <- chanfile.RecvMsg{chanfile.Commit, nil}
-		}
+//expand parses the parts of a loaded file that are
+//only parsed once used, on the way to parts.
+func (fs *MKVfs) expand(parts []string) {
+	if len(parts) < 3 {
+		return
+	}
+	s, err := fs.session(parts[0])
+	if err != nil {
+		return
+	}
+	if m, ok := s.file(parts[1]); ok {
+		m.expand(parts[2:])
 	}
 }
 
 func (fs *MKVfs) Stat(fpath string) (os.FileInfo, error) {
-	fs.RLock()
-	defer fs.RUnlock()
 	switch fpath {
 	case "/":
 		return fs.root.Stat()
 	default:
+		//Stat does not read the file itself
+		parts := split(fpath)
+		fs.expand(parts[:len(parts)-1])
 		return fs.root.Walk(fpath)
 	}
 }
 
 func (fs *MKVfs) ReadDir(fpath string) (ffs.Dir, error) {
-	fs.RLock()
-	defer fs.RUnlock()
 	switch fpath {
 	case "/":
 		return fs.root.Dup(), nil
 	default:
+		fs.expand(split(fpath))
 		return fs.root.WalkForDir(fpath)
 	}
 }
 
+//open opens a file in the tree, which is either
+//an in memory file, a synthetic one, the raw data of
+//an element or a track's stream.
+func (fs *MKVfs) open(fpath string, mode int) (ffs.Writer, error) {
+	fi, err := fs.root.Walk(fpath)
+	if err != nil {
+		return nil, err
+	}
+	switch f := fi.Sys().(type) {
+	case *fsutil.File:
+		return f.Open(mode)
+	case *rawfile:
+		return f.Open()
+	case *streamfile:
+		return f.Open()
+	case *synthfile.File:
+		return f.Open(mode)
+	}
+	return nil, fsutil.ErrCastFile
+}
+
 func (fs *MKVfs) Open(fpath string, mode int) (ffs.File, error) {
-	fs.RLock()
-	defer fs.RUnlock()
-	switch fpath {
-	case "/mkv":
-		return fs.path.Dup(), nil
-	case "/Block":
-		return fs.d.Block.Dup(), nil
-	case "/EBML":
-		return fs.d.EBML.Dup(), nil
+	if fpath == "/new" {
+		return fs.clone(), nil
+	}
+	s, err := fs.session(fpath)
+	if err != nil {
+		return nil, err
+	}
+	//The session may have been collected since we looked it up
+	if !s.acquire() {
+		return nil, os.ErrNotExist
+	}
+	var f ffs.Writer
+	switch parts := split(fpath); {
+	case len(parts) == 2 && parts[1] == "ctl":
+		f, err = s.ctl.Open(mode)
+	case len(parts) == 3 && parts[2] == "data":
+		if m, ok := s.file(parts[1]); ok {
+			f, err = m.d.Data.Open(mode)
+		} else {
+			err = os.ErrNotExist
+		}
 	default:
-		return fs.root.WalkForFile(fpath)
+		fs.expand(parts)
+		f, err = fs.open(fpath, mode)
+	}
+	if err != nil {
+		s.release()
+		return nil, err
 	}
-}
\ No newline at end of file
+	return &ref{f, s, sync.Once{}}, nil
+}
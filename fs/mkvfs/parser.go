@@ -1,11 +1,10 @@
 package mkvfs
 
 import (
-	"fmt"
-	"strings"
+	"os"
 	"strconv"
+	"strings"
 	"time"
-	"os"
 
 	"github.com/majiru/ffs/pkg/fsutil"
 	"github.com/remko/go-mkvparse"
@@ -17,127 +16,152 @@ func (p parseError) Error() string {
 	return "mkvfs.TreeParser: " + string(p)
 }
 
-type crumb struct {
-	dir *fsutil.Dir
-	parent *crumb
-}
-
+//TreeParser builds a directory for every element it is handed.
+//Each directory holds offset, size and type files,
+//elements with a value also get a value file,
+//and the directories of masters hold those of their children.
+//If the parser has a source, elements of known size also
+//get a raw file holding their data.
 type TreeParser struct {
 	Root *fsutil.Dir
-	cur *fsutil.Dir
-	parent *crumb
-	lastlevel int
+	//Schema names the elements
+	Schema *Schema
+	src    source
+	stack []*fsutil.Dir
+	//elems holds the element of each directory in stack
+	elems []elem
+	//lazy, if set, is handed the Clusters of known size
+	//with their path from Root, their children are left to it
+	lazy func(fpath string, c *cluster)
+	//edit, if set, is handed the value files of editable elements
+	//with the elements leading to them and the directory of their
+	//master, and returns the file to use
+	edit func(path []elem, master *fsutil.Dir, f *fsutil.File) os.FileInfo
+	//scale is the TimecodeScale of the segment in nanoseconds
+	scale    int64
+	duration *fsutil.File
+	rawdur   float64
 }
 
 func NewTreeParser(root *fsutil.Dir) *TreeParser {
 	if root == nil {
 		root = fsutil.CreateDir("/")
 	}
-	return &TreeParser{root, nil, &crumb{nil, nil}, 0}
+	return &TreeParser{root, Matroska, nil, nil, nil, nil, nil, 1000000, nil, 0}
 }
 
-//We need each filename to be unique, appending an incrementing
-//numeric is a lazy way to do it
-func getunique(d *fsutil.Dir, name, cur string) (string, error) {
-	var tosearch string
-	if cur != "" {
-		tosearch = cur
-	} else {
-		tosearch = name
+func (p *TreeParser) cur() *fsutil.Dir {
+	if len(p.stack) == 0 {
+		return p.Root
 	}
-	fi, err := d.Find(tosearch)
-	if err == os.ErrNotExist {
-		return tosearch, nil
-	}
-	tail := strings.TrimPrefix(fi.Name(), name)
-	if len(tail) == 0 {
-		return getunique(d, name, fmt.Sprintf("%s%d", name, 2))
+	return p.stack[len(p.stack)-1]
+}
+
+//element adds the directory for an element to the current master.
+func (p *TreeParser) element(id mkvparse.ElementID, info mkvparse.ElementInfo, typ string) *fsutil.Dir {
+	//Elements may repeat, so each one gets a numbered name
+	name := p.cur().UniqueName(p.Schema.Name(id))
+	dir := fsutil.CreateDir(name,
+		fsutil.CreateFile([]byte(strconv.FormatInt(info.Offset, 10)), 0444, "offset").Stats,
+		fsutil.CreateFile([]byte(strconv.FormatInt(info.Size, 10)), 0444, "size").Stats,
+		fsutil.CreateFile([]byte(typ), 0444, "type").Stats)
+	if p.src != nil && info.Size >= 0 {
+		dir.Append(&rawfile{p.src, "raw", info.Offset, info.Size})
 	}
-	if i, err := strconv.Atoi(tail); err == nil {
-		i++
-		return getunique(d, name, fmt.Sprintf("%s%d", name, i))
-	} else {
-		return "", err
+	p.cur().Append(dir.Stats)
+	return dir
+}
+
+func (p *TreeParser) value(id mkvparse.ElementID, info mkvparse.ElementInfo, typ, value string) *fsutil.File {
+	if p.edit != nil && editable[id] != 0 {
+		f := fsutil.CreateFile([]byte(value), 0444, "value")
+		path := append(append([]elem{}, p.elems...), elem{id, info.Offset, info.Size})
+		master := p.cur()
+		p.element(id, info, typ).Append(p.edit(path, master, f))
+		return f
 	}
+	f := fsutil.CreateFile([]byte(value), 0444, "value")
+	p.element(id, info, typ).Append(f.Stats)
+	return f
 }
 
-func genunique(d *fsutil.Dir, name string) (string, error) {
-	return getunique(d, name, "")
+//path returns the path from Root to the current master.
+func (p *TreeParser) path() string {
+	names := make([]string, len(p.stack))
+	for i, dir := range p.stack {
+		names[i] = dir.Stats.Name()
+	}
+	return strings.Join(names, "/")
 }
 
 func (p *TreeParser) HandleMasterBegin(id mkvparse.ElementID, info mkvparse.ElementInfo) (bool, error) {
-	if info.Level == 0 {
-		newdir := fsutil.CreateDir(mkvparse.NameForElementID(id))
-		p.Root.Append(newdir.Stats)
-		p.cur = newdir
-		p.parent = &crumb{p.cur, nil}
-		p.lastlevel = 0
-		return true, nil
+	dir := p.element(id, info, "master")
+	p.stack = append(p.stack, dir)
+	p.elems = append(p.elems, elem{id, info.Offset, info.Size})
+	if id == mkvparse.ClusterElement && p.lazy != nil && info.Size >= 0 {
+		p.lazy(p.path(), &cluster{dir: dir, off: info.Offset, size: info.Size, scale: p.scale})
+		return false, nil
 	}
-	switch {
-	case info.Level > p.lastlevel:
-		if info.Level != p.lastlevel+1 {
-			return false, parseError("Multi level descent")
-		}
-		p.parent = &crumb{p.cur, p.parent}
-	case info.Level < p.lastlevel:
-		for i := p.lastlevel; i != info.Level; i-- {
-			if p.parent.parent == nil {
-				return false, parseError("Illegal climb on " + mkvparse.NameForElementID(id))
-			}
-			p.parent = p.parent.parent
-		}
-		fallthrough
-	default:
-		p.cur = p.parent.dir
-	}
-	name, err := genunique(p.cur, mkvparse.NameForElementID(id))
-	if err != nil {
-		return false, err
-	}
-	newdir := fsutil.CreateDir(name)
-	p.cur.Append(newdir.Stats)
-	p.cur = newdir
-	p.lastlevel = info.Level
 	return true, nil
 }
 
 func (p *TreeParser) HandleMasterEnd(id mkvparse.ElementID, info mkvparse.ElementInfo) error {
+	if len(p.stack) == 0 {
+		return parseError("Unbalanced end of " + p.Schema.Name(id))
+	}
+	p.stack = p.stack[:len(p.stack)-1]
+	p.elems = p.elems[:len(p.elems)-1]
+	//Duration may come before the TimecodeScale it is counted in
+	if id == mkvparse.InfoElement && p.duration != nil {
+		p.duration.Truncate(0)
+		p.duration.WriteAt([]byte(formatTimecode(p.rawdur, p.scale)), 0)
+		p.duration = nil
+	}
 	return nil
 }
 
-func formatinfo(info *mkvparse.ElementInfo) string {
-	return strconv.FormatInt(info.Offset, 10) + " " + strconv.FormatInt(info.Size, 10)
+//matroska tells whether values are formatted as Matroska's,
+//other document types are shown as they are.
+func (p *TreeParser) matroska() bool {
+	return p.Schema == Matroska || p.Schema == WebM
 }
 
-func (p *TreeParser) write(id *mkvparse.ElementID, info *mkvparse.ElementInfo) error {
-	if p.cur == nil {
-		return parseError("Orphaned element")
-	}
-	name, err := genunique(p.cur, mkvparse.NameForElementID(*id))
-	if err != nil {
-		return err
+func (p *TreeParser) HandleString(id mkvparse.ElementID, value string, info mkvparse.ElementInfo) error {
+	if p.matroska() {
+		value = formatString(id, value)
 	}
-	p.cur.Append(fsutil.CreateFile([]byte(formatinfo(info)), 0644, name).Stats)
+	p.value(id, info, "string", value)
 	return nil
 }
 
-func (p *TreeParser) HandleString(id mkvparse.ElementID, value string, info mkvparse.ElementInfo) error {
-	return p.write(&id, &info)
-}
-
 func (p *TreeParser) HandleInteger(id mkvparse.ElementID, value int64, info mkvparse.ElementInfo) error {
-	return p.write(&id, &info)
+	if !p.matroska() {
+		p.value(id, info, "integer", strconv.FormatInt(value, 10))
+		return nil
+	}
+	if id == mkvparse.TimecodeScaleElement && value > 0 {
+		p.scale = value
+	}
+	p.value(id, info, "integer", formatInteger(id, value, p.scale))
+	return nil
 }
 
 func (p *TreeParser) HandleFloat(id mkvparse.ElementID, value float64, info mkvparse.ElementInfo) error {
-	return p.write(&id, &info)
+	if id == mkvparse.DurationElement && p.matroska() {
+		p.duration = p.value(id, info, "float", formatTimecode(value, p.scale))
+		p.rawdur = value
+		return nil
+	}
+	p.value(id, info, "float", strconv.FormatFloat(value, 'g', -1, 64))
+	return nil
 }
 
 func (p *TreeParser) HandleDate(id mkvparse.ElementID, value time.Time, info mkvparse.ElementInfo) error {
-	return p.write(&id, &info)
+	p.value(id, info, "date", value.UTC().Format(time.RFC3339))
+	return nil
 }
 
 func (p *TreeParser) HandleBinary(id mkvparse.ElementID, value []byte, info mkvparse.ElementInfo) error {
-	return p.write(&id, &info)
-}
\ No newline at end of file
+	p.value(id, info, "binary", formatBinary(value))
+	return nil
+}
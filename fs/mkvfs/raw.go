@@ -0,0 +1,57 @@
+package mkvfs
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/majiru/ffs"
+	"github.com/majiru/ffs/pkg/fsutil"
+)
+
+//source gives access to the file a tree was parsed from.
+type source interface {
+	readerAt() (io.ReaderAt, error)
+	modTime() time.Time
+}
+
+//rawfile is the raw file of an element, holding the bytes of its data.
+//It is its own os.FileInfo and is read straight from the loaded file,
+//nothing is held in memory.
+type rawfile struct {
+	src  source
+	name string
+	off  int64
+	size int64
+}
+
+func (r *rawfile) Name() string       { return r.name }
+func (r *rawfile) Size() int64        { return r.size }
+func (r *rawfile) Mode() os.FileMode  { return 0444 }
+func (r *rawfile) ModTime() time.Time { return r.src.modTime() }
+func (r *rawfile) IsDir() bool        { return false }
+func (r *rawfile) Sys() interface{}   { return r }
+
+func (r *rawfile) Open() (ffs.Writer, error) {
+	f, err := r.src.readerAt()
+	if err != nil {
+		return nil, err
+	}
+	return &rawhandle{io.NewSectionReader(f, r.off, r.size), r}, nil
+}
+
+//rawhandle is an open rawfile or streamfile, writes are refused
+//as they are on read only fsutil handles.
+type rawhandle struct {
+	*io.SectionReader
+	fi os.FileInfo
+}
+
+func (h *rawhandle) Stat() (os.FileInfo, error) { return h.fi, nil }
+
+//Close leaves the loaded file open, it belongs to the session.
+func (h *rawhandle) Close() error { return nil }
+
+func (h *rawhandle) Write(b []byte) (int, error)              { return 0, fsutil.ErrReadOnly }
+func (h *rawhandle) WriteAt(b []byte, off int64) (int, error) { return 0, fsutil.ErrReadOnly }
+func (h *rawhandle) Truncate(size int64) error                { return fsutil.ErrReadOnly }
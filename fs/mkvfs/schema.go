@@ -0,0 +1,241 @@
+package mkvfs
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/remko/go-mkvparse"
+)
+
+var ErrSchema = errors.New("mkvfs: malformed EBML schema")
+
+//schemaEntry is an element as listed in a schema.
+type schemaEntry struct {
+	id   mkvparse.ElementID
+	typ  string
+	path string
+}
+
+type schemaElement struct {
+	name string
+	typ  string
+	//level is the depth of the element in the document,
+	//global elements such as Void have a level of -1
+	level int
+}
+
+//Schema names the elements of an EBML document type and gives their types.
+//Elements missing from it are shown by their hex ID and read as binary.
+//Files are parsed with the schema their DocType names, sessions know
+//matroska and WebM and read others from XML.
+type Schema struct {
+	DocType  string
+	elements map[mkvparse.ElementID]schemaElement
+	//excluded elements are defined by the schema the document type
+	//restricts, codecs if set lists the codecs it allows
+	excluded map[mkvparse.ElementID]bool
+	codecs   map[string]bool
+}
+
+func newSchema(docType string) *Schema {
+	return &Schema{DocType: docType, elements: make(map[mkvparse.ElementID]schemaElement)}
+}
+
+func level(path string) int {
+	if strings.Contains(path, "(") {
+		return -1
+	}
+	return strings.Count(strings.TrimPrefix(path, `\`), `\`)
+}
+
+func (s *Schema) add(e schemaEntry, name string) {
+	s.elements[e.id] = schemaElement{name, e.typ, level(e.path)}
+}
+
+//Name returns the name of the element id, or its ID in hex if it is unknown.
+func (s *Schema) Name(id mkvparse.ElementID) string {
+	if e, ok := s.elements[id]; ok {
+		return e.name
+	}
+	return fmt.Sprintf("0x%X", uint64(id))
+}
+
+func (s *Schema) element(id mkvparse.ElementID) (schemaElement, bool) {
+	e, ok := s.elements[id]
+	return e, ok
+}
+
+//Matroska is the schema of Matroska documents.
+var Matroska = newSchema("matroska")
+
+//WebM is the schema of WebM, Matroska restricted
+//to fewer elements and codecs.
+var WebM = newSchema("webm")
+
+//ebmlHeader is the schema of the EBML header, which every document starts with.
+var ebmlHeader = newSchema("")
+
+//webmExcluded lists the Matroska elements WebM leaves out.
+var webmExcluded = []mkvparse.ElementID{
+	mkvparse.AttachmentsElement,
+	mkvparse.SegmentFamilyElement,
+	mkvparse.ChapterTranslateElement,
+	mkvparse.PrevUIDElement,
+	mkvparse.PrevFilenameElement,
+	mkvparse.NextUIDElement,
+	mkvparse.NextFilenameElement,
+	mkvparse.SilentTracksElement,
+	mkvparse.BlockVirtualElement,
+	mkvparse.ReferenceVirtualElement,
+	mkvparse.EncryptedBlockElement,
+	mkvparse.SlicesElement,
+	mkvparse.TrackTranslateElement,
+	mkvparse.TrackOverlayElement,
+	mkvparse.TrackOperationElement,
+	mkvparse.TrackTimecodeScaleElement,
+	mkvparse.AttachmentLinkElement,
+	mkvparse.ContentCompressionElement,
+	mkvparse.ChapProcessElement,
+	mkvparse.ChapterPhysicalEquivElement,
+	mkvparse.ChapterSegmentUIDElement,
+	mkvparse.ChapterSegmentEditionUIDElement,
+}
+
+var webmCodecs = []string{"V_VP8", "V_VP9", "V_AV1", "A_VORBIS", "A_OPUS", "D_WEBVTT/SUBTITLES",
+	"D_WEBVTT/CAPTIONS", "D_WEBVTT/DESCRIPTIONS", "D_WEBVTT/METADATA"}
+
+func init() {
+	for _, e := range matroskaElements {
+		name := mkvparse.NameForElementID(e.id)
+		Matroska.add(e, name)
+		WebM.add(e, name)
+		if strings.HasPrefix(e.path, `\EBML`) || level(e.path) < 0 {
+			ebmlHeader.add(e, name)
+		}
+	}
+	WebM.excluded = make(map[mkvparse.ElementID]bool)
+	for _, id := range webmExcluded {
+		WebM.excluded[id] = true
+	}
+	WebM.codecs = make(map[string]bool)
+	for _, c := range webmCodecs {
+		WebM.codecs[c] = true
+	}
+}
+
+type xmlSchema struct {
+	DocType  string `xml:"docType,attr"`
+	Elements []struct {
+		Name string `xml:"name,attr"`
+		Path string `xml:"path,attr"`
+		ID   string `xml:"id,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"element"`
+}
+
+var schemaTypes = map[string]bool{
+	"master": true, "uinteger": true, "integer": true, "float": true,
+	"string": true, "utf-8": true, "date": true, "binary": true,
+}
+
+//LoadSchema reads an EBML schema in the XML format of RFC 8794.
+//The EBML header elements do not have to be listed.
+func LoadSchema(r io.Reader) (*Schema, error) {
+	var x xmlSchema
+	if err := xml.NewDecoder(r).Decode(&x); err != nil {
+		return nil, err
+	}
+	if x.DocType == "" {
+		return nil, ErrSchema
+	}
+	s := newSchema(x.DocType)
+	for id, e := range ebmlHeader.elements {
+		s.elements[id] = e
+	}
+	for _, e := range x.Elements {
+		id, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(e.ID), "0x"), 16, 32)
+		if err != nil || e.Name == "" || !schemaTypes[e.Type] {
+			return nil, ErrSchema
+		}
+		s.add(schemaEntry{mkvparse.ElementID(id), e.Type, e.Path}, e.Name)
+	}
+	return s, nil
+}
+
+//restrictions notes the elements and codecs of a document
+//that its schema leaves out, they are listed in its status.
+type restrictions struct {
+	sync.Mutex
+	s     *Schema
+	found map[string]bool
+	order []string
+}
+
+func newRestrictions(s *Schema) *restrictions {
+	return &restrictions{s: s, found: make(map[string]bool)}
+}
+
+func (r *restrictions) note(problem string) {
+	r.Lock()
+	defer r.Unlock()
+	if !r.found[problem] {
+		r.found[problem] = true
+		r.order = append(r.order, problem)
+	}
+}
+
+func (r *restrictions) check(id mkvparse.ElementID) {
+	if r.s.excluded[id] {
+		r.note(r.s.Name(id) + " not allowed")
+	}
+}
+
+//list returns what was found, in the order it was first seen.
+func (r *restrictions) list() []string {
+	r.Lock()
+	defer r.Unlock()
+	return append([]string{}, r.order...)
+}
+
+func (r *restrictions) HandleMasterBegin(id mkvparse.ElementID, info mkvparse.ElementInfo) (bool, error) {
+	r.check(id)
+	return true, nil
+}
+
+func (r *restrictions) HandleMasterEnd(id mkvparse.ElementID, info mkvparse.ElementInfo) error {
+	return nil
+}
+
+func (r *restrictions) HandleString(id mkvparse.ElementID, value string, info mkvparse.ElementInfo) error {
+	r.check(id)
+	if id == mkvparse.CodecIDElement && r.s.codecs != nil && !r.s.codecs[value] {
+		r.note("codec " + value + " not allowed")
+	}
+	return nil
+}
+
+func (r *restrictions) HandleInteger(id mkvparse.ElementID, value int64, info mkvparse.ElementInfo) error {
+	r.check(id)
+	return nil
+}
+
+func (r *restrictions) HandleFloat(id mkvparse.ElementID, value float64, info mkvparse.ElementInfo) error {
+	r.check(id)
+	return nil
+}
+
+func (r *restrictions) HandleDate(id mkvparse.ElementID, value time.Time, info mkvparse.ElementInfo) error {
+	r.check(id)
+	return nil
+}
+
+func (r *restrictions) HandleBinary(id mkvparse.ElementID, value []byte, info mkvparse.ElementInfo) error {
+	r.check(id)
+	return nil
+}
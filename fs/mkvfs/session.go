@@ -0,0 +1,232 @@
+package mkvfs
+
+import (
+	"context"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/majiru/ffs"
+	"github.com/majiru/ffs/pkg/ctl"
+	"github.com/majiru/ffs/pkg/fsutil"
+)
+
+//session is the state behind one numbered directory.
+//Every file loaded in it gets a directory of its own,
+//named after the file. Its ctl loads and unloads files,
+//reads schemas and turns editing of value files on and off.
+type session struct {
+	sync.Mutex
+	id    int
+	fs    *MKVfs
+	dir   *fsutil.Dir
+	ctl   *ctl.Ctl
+	files map[string]*mkv
+	//schemas holds the schemas of the document types
+	//files can be loaded as, by DocType
+	schemas map[string]*Schema
+	refs    int
+	timer   *time.Timer
+	gone    bool
+	//editing is given to the files loaded, see mkv.setEditing
+	editing bool
+}
+
+func newSession(fs *MKVfs, id int) *session {
+	s := &session{id: id, fs: fs, files: make(map[string]*mkv)}
+	s.schemas = map[string]*Schema{Matroska.DocType: Matroska, WebM.DocType: WebM}
+	s.ctl = ctl.New("ctl", 0644)
+	s.ctl.Register(ctl.Cmd{
+		Name:    "load",
+		Args:    "path",
+		Help:    "parse the matroska file at path into a new directory",
+		MinArgs: 1,
+		MaxArgs: 1,
+		Fn:      func(args []string) error { return s.load(args[0]) },
+	})
+	s.ctl.Register(ctl.Cmd{
+		Name:    "unload",
+		Args:    "name",
+		Help:    "remove the directory of a loaded file",
+		MinArgs: 1,
+		MaxArgs: 1,
+		Fn:      func(args []string) error { return s.unload(args[0]) },
+	})
+	s.ctl.Register(ctl.Cmd{
+		Name:    "schema",
+		Args:    "path",
+		Help:    "read an EBML schema XML file for the files loaded after it",
+		MinArgs: 1,
+		MaxArgs: 1,
+		Fn:      func(args []string) error { return s.schema(args[0]) },
+	})
+	s.ctl.Register(ctl.Cmd{
+		Name:    "edit",
+		Args:    "on|off",
+		Help:    "allow writing the value files of loaded files",
+		MinArgs: 1,
+		MaxArgs: 1,
+		Fn:      func(args []string) error { return s.edit(args[0]) },
+	})
+	s.ctl.Status = s.status
+	s.dir = fsutil.CreateDir(strconv.Itoa(id), s.ctl.Content.Stats)
+	return s
+}
+
+//load adds fpath to the session under the next free name for its base name.
+//The file is parsed in the background, the directory is visible
+//while parsing and its status is shown in ctl and its status file.
+func (s *session) load(fpath string) error {
+	s.Lock()
+	name := s.dir.UniqueName(path.Base(fpath))
+	m, err := openMKV(fpath, name)
+	if err != nil {
+		s.Unlock()
+		return err
+	}
+	for dt, schema := range s.schemas {
+		m.schemas[dt] = schema
+	}
+	m.editing = s.editing
+	s.files[name] = m
+	s.dir.Append(m.dir.Stats)
+	s.Unlock()
+	go m.parse()
+	return nil
+}
+
+//schema reads the schema at fpath, replacing
+//any schema of the same document type.
+func (s *session) schema(fpath string) error {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	schema, err := LoadSchema(f)
+	if err != nil {
+		return err
+	}
+	s.Lock()
+	s.schemas[schema.DocType] = schema
+	s.Unlock()
+	return nil
+}
+
+//edit turns editing on or off for the files loaded and those to come.
+func (s *session) edit(arg string) error {
+	var on bool
+	switch arg {
+	case "on":
+		on = true
+	case "off":
+	default:
+		return ctl.Usage("edit on|off")
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.editing = on
+	for _, m := range s.files {
+		m.setEditing(on)
+	}
+	return nil
+}
+
+func (s *session) unload(name string) error {
+	s.Lock()
+	m, ok := s.files[name]
+	if ok {
+		delete(s.files, name)
+		s.dir.Remove(name)
+	}
+	s.Unlock()
+	if !ok {
+		return ErrNotLoaded
+	}
+	m.close()
+	return nil
+}
+
+//file returns the loaded file called name.
+func (s *session) file(name string) (*mkv, bool) {
+	s.Lock()
+	defer s.Unlock()
+	m, ok := s.files[name]
+	return m, ok
+}
+
+//status lists the loaded files, one per line as
+//name size status path
+func (s *session) status() string {
+	s.Lock()
+	lines := make([]string, 0, len(s.files))
+	for _, m := range s.files {
+		lines = append(lines, m.String())
+	}
+	s.Unlock()
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+//acquire holds the session open until the matching release,
+//it fails if the session has already been collected.
+func (s *session) acquire() bool {
+	s.Lock()
+	defer s.Unlock()
+	if s.gone {
+		return false
+	}
+	s.refs++
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	return true
+}
+
+//release drops a hold on the session, once there are none left
+//the session is collected after fs.Idle.
+func (s *session) release() {
+	s.Lock()
+	defer s.Unlock()
+	if s.refs--; s.refs > 0 {
+		return
+	}
+	if s.fs.Idle <= 0 {
+		s.fs.collect(s)
+		return
+	}
+	s.timer = time.AfterFunc(s.fs.Idle, func() {
+		s.Lock()
+		defer s.Unlock()
+		//The timer may have fired just as the session was reopened
+		if s.refs == 0 && s.timer != nil {
+			s.fs.collect(s)
+		}
+	})
+}
+
+//ref is an open file of a session,
+//closing it releases its hold on the session.
+type ref struct {
+	ffs.Writer
+	s    *session
+	once sync.Once
+}
+
+//SetContext passes the request context on to the file, if it takes one.
+func (r *ref) SetContext(ctx context.Context) {
+	if c, ok := r.Writer.(interface{ SetContext(context.Context) }); ok {
+		c.SetContext(ctx)
+	}
+}
+
+func (r *ref) Close() error {
+	err := r.Writer.Close()
+	r.once.Do(r.s.release)
+	return err
+}
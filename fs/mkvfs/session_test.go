@@ -0,0 +1,26 @@
+package mkvfs
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/majiru/ffs"
+	"github.com/majiru/ffs/pkg/fsutil"
+)
+
+func TestRefContext(t *testing.T) {
+	f := fsutil.CreateFile([]byte{}, 0644, "data")
+	h, err := f.Open(os.O_RDWR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &ref{Writer: h}
+	r.SetContext(context.WithValue(context.Background(), ffs.UserKey{}, "glenda"))
+	if _, err = r.Write([]byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if f.Stats.Muid() != "glenda" {
+		t.Fatalf("expected %s got %s for Muid", "glenda", f.Stats.Muid())
+	}
+}
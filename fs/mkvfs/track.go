@@ -0,0 +1,351 @@
+package mkvfs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/majiru/ffs"
+	"github.com/majiru/ffs/pkg/fsutil"
+	"github.com/remko/go-mkvparse"
+)
+
+//frame is the payload of one frame in the loaded file,
+//time and dur are counted in TimecodeScale.
+type frame struct {
+	off  int64
+	size int64
+	time int64
+	dur  int64
+}
+
+//track is a TrackEntry and the frames of its blocks.
+type track struct {
+	num     uint64
+	typ     int64
+	codec   string
+	name    string
+	lang    string
+	def     bool
+	private []byte
+	//defdur is the DefaultDuration of the track in nanoseconds
+	defdur int64
+	frames []frame
+}
+
+func newTrack(num uint64) *track {
+	//Language and FlagDefault have defaults when absent
+	return &track{num: num, lang: "eng", def: true}
+}
+
+//demuxer collects the tracks of a file and where their frames are.
+//It only keeps offsets, payloads are read from the source when needed.
+type demuxer struct {
+	mkvparse.DefaultHandler
+	src    source
+	tracks map[uint64]*track
+	entry  *track
+	scale  int64
+	//cluster is the Timecode of the current cluster
+	cluster int64
+	//group is the track of the Block in the current BlockGroup,
+	//first is the index of its first frame
+	group *track
+	first int
+	dur   int64
+}
+
+func newDemuxer(src source) *demuxer {
+	return &demuxer{src: src, tracks: make(map[uint64]*track), scale: 1000000}
+}
+
+//track returns the track numbered num, blocks
+//may come before the TrackEntry describing them.
+func (d *demuxer) track(num uint64) *track {
+	t, ok := d.tracks[num]
+	if !ok {
+		t = newTrack(num)
+		d.tracks[num] = t
+	}
+	return t
+}
+
+func (d *demuxer) HandleMasterBegin(id mkvparse.ElementID, info mkvparse.ElementInfo) (bool, error) {
+	switch id {
+	case mkvparse.TrackEntryElement:
+		d.entry = newTrack(0)
+	case mkvparse.ClusterElement:
+		d.cluster = 0
+	case mkvparse.BlockGroupElement:
+		d.group, d.dur = nil, 0
+	}
+	return true, nil
+}
+
+func (d *demuxer) HandleMasterEnd(id mkvparse.ElementID, info mkvparse.ElementInfo) error {
+	switch id {
+	case mkvparse.TrackEntryElement:
+		if t, ok := d.tracks[d.entry.num]; ok {
+			d.entry.frames = t.frames
+		}
+		d.tracks[d.entry.num] = d.entry
+		d.entry = nil
+	case mkvparse.BlockGroupElement:
+		//BlockDuration may follow the Block it is for
+		if d.group != nil {
+			for i := d.first; i < len(d.group.frames); i++ {
+				d.group.frames[i].dur = d.dur
+			}
+		}
+		d.group = nil
+	}
+	return nil
+}
+
+func (d *demuxer) HandleString(id mkvparse.ElementID, value string, info mkvparse.ElementInfo) error {
+	if d.entry == nil {
+		return nil
+	}
+	switch id {
+	case mkvparse.CodecIDElement:
+		d.entry.codec = value
+	case mkvparse.NameElement:
+		d.entry.name = value
+	case mkvparse.LanguageElement, mkvparse.LanguageIETFElement:
+		d.entry.lang = value
+	}
+	return nil
+}
+
+func (d *demuxer) HandleInteger(id mkvparse.ElementID, value int64, info mkvparse.ElementInfo) error {
+	switch id {
+	case mkvparse.TimecodeScaleElement:
+		if value > 0 {
+			d.scale = value
+		}
+	case mkvparse.TimecodeElement:
+		d.cluster = value
+	case mkvparse.BlockDurationElement:
+		d.dur = value
+	}
+	if d.entry == nil {
+		return nil
+	}
+	switch id {
+	case mkvparse.TrackNumberElement:
+		d.entry.num = uint64(value)
+	case mkvparse.TrackTypeElement:
+		d.entry.typ = value
+	case mkvparse.FlagDefaultElement:
+		d.entry.def = value != 0
+	case mkvparse.DefaultDurationElement:
+		d.entry.defdur = value
+	}
+	return nil
+}
+
+func (d *demuxer) HandleBinary(id mkvparse.ElementID, value []byte, info mkvparse.ElementInfo) error {
+	switch id {
+	case mkvparse.CodecPrivateElement:
+		if d.entry != nil {
+			d.entry.private = value
+		}
+		return nil
+	case mkvparse.SimpleBlockElement, mkvparse.BlockElement:
+	default:
+		return nil
+	}
+	blk, err := parseBlock(value, id == mkvparse.SimpleBlockElement)
+	if err != nil {
+		//A damaged block is skipped, the rest of the track is still usable
+		return nil
+	}
+	t := d.track(blk.Track)
+	if id == mkvparse.BlockElement {
+		d.group, d.first = t, len(t.frames)
+	}
+	for _, f := range blk.Frames {
+		t.frames = append(t.frames, frame{info.Offset + f.Offset, f.Size, d.cluster + int64(blk.Timecode), 0})
+	}
+	return nil
+}
+
+//dir builds the tracks directory, with a directory for every track
+//holding its metadata, its stream and for text subtitles
+//the subtitles rendered from it.
+func (d *demuxer) dir() (*fsutil.Dir, error) {
+	tracks := fsutil.CreateSortedDir("tracks")
+	for _, t := range d.tracks {
+		sort.SliceStable(t.frames, func(i, j int) bool { return t.frames[i].time < t.frames[j].time })
+		dir := fsutil.CreateDir(strconv.FormatUint(t.num, 10),
+			fsutil.CreateFile([]byte(formatInteger(mkvparse.TrackTypeElement, t.typ, d.scale)), 0444, "type").Stats,
+			fsutil.CreateFile([]byte(formatString(mkvparse.CodecIDElement, t.codec)), 0444, "codec").Stats,
+			fsutil.CreateFile([]byte(t.lang), 0444, "language").Stats,
+			fsutil.CreateFile([]byte(strconv.FormatBool(t.def)), 0444, "default").Stats,
+			newStreamfile(d.src, t.frames))
+		if t.name != "" {
+			dir.Append(fsutil.CreateFile([]byte(t.name), 0444, "name").Stats)
+		}
+		name, render := subtitles(t.codec)
+		if render != nil {
+			b, err := d.subtitles(t, render)
+			if err != nil {
+				return tracks, err
+			}
+			dir.Append(fsutil.CreateFile(b, 0444, name).Stats)
+		}
+		tracks.Append(dir.Stats)
+	}
+	return tracks, nil
+}
+
+//renderer turns the payloads of a subtitle track into a file,
+//cues are given in order with their times in nanoseconds.
+type renderer func(t *track, cues []cue) []byte
+
+type cue struct {
+	start, end int64
+	text       string
+}
+
+//subtitles returns the file name and renderer for a subtitle codec.
+func subtitles(codec string) (string, renderer) {
+	switch codec {
+	case "S_TEXT/UTF8":
+		return "subtitles.srt", renderSRT
+	case "S_TEXT/ASS":
+		return "subtitles.ass", renderASS
+	case "S_TEXT/SSA":
+		return "subtitles.ssa", renderASS
+	}
+	return "", nil
+}
+
+func (d *demuxer) subtitles(t *track, render renderer) ([]byte, error) {
+	r, err := d.src.readerAt()
+	if err != nil {
+		return nil, err
+	}
+	cues := make([]cue, len(t.frames))
+	for i, f := range t.frames {
+		b := make([]byte, f.size)
+		if _, err = r.ReadAt(b, f.off); err != nil {
+			return nil, err
+		}
+		start := f.time * d.scale
+		dur := f.dur * d.scale
+		if f.dur == 0 {
+			dur = t.defdur
+		}
+		cues[i] = cue{start, start + dur, strings.TrimRight(string(b), "\x00")}
+	}
+	return render(t, cues), nil
+}
+
+func renderSRT(t *track, cues []cue) []byte {
+	var sb strings.Builder
+	for i, c := range cues {
+		fmt.Fprintf(&sb, "%d\n%s --> %s\n%s\n\n", i+1, srtTime(c.start), srtTime(c.end), c.text)
+	}
+	return []byte(sb.String())
+}
+
+//assEvents is the Events header used when CodecPrivate lacks one.
+const assEvents = "[Events]\nFormat: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n"
+
+//renderASS puts the Dialogue lines after the script header in CodecPrivate.
+//Each payload is ReadOrder, Layer, Style, Name, MarginL, MarginR,
+//MarginV, Effect, Text, the times of the line come from the block.
+func renderASS(t *track, cues []cue) []byte {
+	var sb strings.Builder
+	header := strings.TrimRight(string(t.private), "\r\n\x00")
+	sb.WriteString(header)
+	sb.WriteString("\n")
+	if !strings.Contains(header, "[Events]") {
+		sb.WriteString("\n" + assEvents)
+	}
+	for _, c := range cues {
+		fields := strings.SplitN(c.text, ",", 9)
+		if len(fields) < 9 {
+			continue
+		}
+		fmt.Fprintf(&sb, "Dialogue: %s,%s,%s,%s\n", fields[1], assTime(c.start), assTime(c.end), strings.Join(fields[2:], ","))
+	}
+	return []byte(sb.String())
+}
+
+func srtTime(ns int64) string {
+	d := time.Duration(ns)
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", int64(d.Hours()), int64(d.Minutes())%60, int64(d.Seconds())%60, d.Milliseconds()%1000)
+}
+
+func assTime(ns int64) string {
+	d := time.Duration(ns)
+	return fmt.Sprintf("%d:%02d:%02d.%02d", int64(d.Hours()), int64(d.Minutes())%60, int64(d.Seconds())%60, d.Milliseconds()/10%100)
+}
+
+//streamfile is the stream file of a track, the payloads of its frames
+//one after another. Like rawfile it is read from the loaded file.
+type streamfile struct {
+	src    source
+	frames []frame
+	//starts holds where each frame begins in the stream
+	starts []int64
+	size   int64
+}
+
+func newStreamfile(src source, frames []frame) *streamfile {
+	s := &streamfile{src: src, frames: frames, starts: make([]int64, len(frames))}
+	for i, f := range frames {
+		s.starts[i] = s.size
+		s.size += f.size
+	}
+	return s
+}
+
+func (s *streamfile) Name() string       { return "stream" }
+func (s *streamfile) Size() int64        { return s.size }
+func (s *streamfile) Mode() os.FileMode  { return 0444 }
+func (s *streamfile) ModTime() time.Time { return s.src.modTime() }
+func (s *streamfile) IsDir() bool        { return false }
+func (s *streamfile) Sys() interface{}   { return s }
+
+func (s *streamfile) Open() (ffs.Writer, error) {
+	r, err := s.src.readerAt()
+	if err != nil {
+		return nil, err
+	}
+	return &rawhandle{io.NewSectionReader(&framereader{r, s}, 0, s.size), s}, nil
+}
+
+//framereader maps offsets in a stream to the frames in the loaded file.
+type framereader struct {
+	r io.ReaderAt
+	s *streamfile
+}
+
+func (fr *framereader) ReadAt(b []byte, off int64) (n int, err error) {
+	s := fr.s
+	i := sort.Search(len(s.starts), func(i int) bool { return s.starts[i]+s.frames[i].size > off })
+	for ; n < len(b) && i < len(s.frames); i++ {
+		f := s.frames[i]
+		skip := off + int64(n) - s.starts[i]
+		want := f.size - skip
+		if rest := int64(len(b) - n); want > rest {
+			want = rest
+		}
+		m, err := fr.r.ReadAt(b[n:n+int(want)], f.off+skip)
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
@@ -0,0 +1,143 @@
+package mkvfs
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/majiru/ffs/pkg/fsutil"
+	"github.com/majiru/ffs/pkg/synthfile"
+)
+
+//el encodes an element with an eight byte size.
+func el(id []byte, data ...[]byte) []byte {
+	var body []byte
+	for _, d := range data {
+		body = append(body, d...)
+	}
+	b := append([]byte{}, id...)
+	b = append(b, 0x01, 0, 0, 0, 0, 0, 0, 0)
+	for i, n := 0, len(body); i < 7; i, n = i+1, n>>8 {
+		b[len(b)-1-i] = byte(n)
+	}
+	return append(b, body...)
+}
+
+//readPath reads the file at fpath in the directory of m.
+func readPath(t *testing.T, m *mkv, fpath string) string {
+	m.expand(split(fpath))
+	fi, err := m.dir.Walk(fpath)
+	if err != nil {
+		t.Fatal("error walking to", fpath, err)
+	}
+	var h io.Reader
+	switch f := fi.Sys().(type) {
+	case *fsutil.File:
+		h, err = f.Open(os.O_RDONLY)
+	case *rawfile:
+		h, err = f.Open()
+	case *streamfile:
+		h, err = f.Open()
+	case *synthfile.File:
+		h, err = f.Open(os.O_RDONLY)
+	default:
+		t.Fatal("unexpected file type for", fpath)
+	}
+	if err != nil {
+		t.Fatal("error opening", fpath, err)
+	}
+	b, err := ioutil.ReadAll(h)
+	if err != nil {
+		t.Fatal("error reading", fpath, err)
+	}
+	return string(b)
+}
+
+//loadBytes loads b as a parsed file, the returned func removes it.
+func loadBytes(t *testing.T, b []byte, schemas ...*Schema) (*mkv, func()) {
+	f, err := ioutil.TempFile("", "mkvfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(b)
+	f.Close()
+	m := openParsed(t, f.Name(), schemas...)
+	return m, func() {
+		m.close()
+		os.Remove(f.Name())
+	}
+}
+
+//openParsed loads and parses the file at fpath,
+//with schemas to choose from besides the built in ones.
+func openParsed(t *testing.T, fpath string, schemas ...*Schema) *mkv {
+	m, err := openMKV(fpath, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range schemas {
+		m.schemas[s.DocType] = s
+	}
+	if err = m.parse(); err != nil {
+		t.Fatal("error parsing:", err)
+	}
+	return m
+}
+
+//trackMKV returns a file with an audio and a subtitle track
+//and a Cluster holding blocks for both.
+func trackMKV() []byte {
+	return el([]byte{0x18, 0x53, 0x80, 0x67},
+		el([]byte{0x15, 0x49, 0xa9, 0x66}, el([]byte{0x2a, 0xd7, 0xb1}, []byte{0x0f, 0x42, 0x40})),
+		el([]byte{0x16, 0x54, 0xae, 0x6b},
+			el([]byte{0xae},
+				el([]byte{0xd7}, []byte{1}),
+				el([]byte{0x83}, []byte{2}),
+				el([]byte{0x86}, []byte("A_OPUS")),
+				el([]byte{0x22, 0xb5, 0x9c}, []byte("jpn"))),
+			el([]byte{0xae},
+				el([]byte{0xd7}, []byte{2}),
+				el([]byte{0x83}, []byte{0x11}),
+				el([]byte{0x86}, []byte("S_TEXT/UTF8")),
+				el([]byte{0x88}, []byte{0}))),
+		el([]byte{0x1f, 0x43, 0xb6, 0x75},
+			el([]byte{0xe7}, []byte{0x03, 0xe8}),
+			el([]byte{0xa3}, []byte{0x81, 0x00, 0x14, 0x80}, []byte("bb")),
+			el([]byte{0xa3}, []byte{0x81, 0x00, 0x00, 0x80}, []byte("aa")),
+			el([]byte{0xa0},
+				el([]byte{0xa1}, []byte{0x82, 0x00, 0x00, 0x00}, []byte("Hi")),
+				el([]byte{0x9b}, []byte{0x01, 0xf4}))))
+}
+
+func TestTracks(t *testing.T) {
+	m, done := loadBytes(t, trackMKV())
+	defer done()
+	tests := []struct {
+		fpath  string
+		expect string
+	}{
+		{"tracks/1/stream", "aabb"},
+		{"tracks/1/language", "jpn"},
+		{"tracks/1/default", "true"},
+		{"tracks/1/codec", "A_OPUS (Opus)"},
+		{"tracks/2/default", "false"},
+		{"tracks/2/type", "17 (subtitle)"},
+		{"tracks/2/stream", "Hi"},
+		{"tracks/2/subtitles.srt", "1\n00:00:01,000 --> 00:00:01,500\nHi\n\n"},
+	}
+	for _, test := range tests {
+		if s := readPath(t, m, test.fpath); s != test.expect {
+			t.Fatalf("expected %q got %q for %s", test.expect, s, test.fpath)
+		}
+	}
+}
+
+func TestRenderASS(t *testing.T) {
+	tr := &track{private: []byte("[Script Info]\nTitle: test\n")}
+	out := string(renderASS(tr, []cue{{1500000000, 3000000000, "0,0,Default,,0,0,0,,Hello, world"}}))
+	expect := "[Script Info]\nTitle: test\n\n" + assEvents + "Dialogue: 0,0:00:01.50,0:00:03.00,Default,,0,0,0,,Hello, world\n"
+	if out != expect {
+		t.Fatalf("expected %q got %q", expect, out)
+	}
+}
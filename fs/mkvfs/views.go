@@ -0,0 +1,197 @@
+package mkvfs
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/majiru/ffs/pkg/fsutil"
+	"github.com/remko/go-mkvparse"
+)
+
+type chapter struct {
+	start, end int64
+	hasEnd     bool
+	title      string
+}
+
+type attachment struct {
+	name, mime, desc string
+	off, size        int64
+}
+
+//cuepoint is a seek point, time is counted in TimecodeScale
+//and cluster is the offset of the Cluster element in the file.
+type cuepoint struct {
+	time    int64
+	cluster int64
+}
+
+//views collects the chapters, attachments and cues of a file,
+//which are shown in directories of their own next to contents.
+type views struct {
+	mkvparse.DefaultHandler
+	src   source
+	scale int64
+	//segment is where the data of the Segment starts,
+	//the positions in cues are counted from it
+	segment     int64
+	chapters    []*chapter
+	stack       []*chapter
+	attachments []*attachment
+	att         *attachment
+	cues        map[uint64][]cuepoint
+	cuetime     int64
+	pos         *cuepoint
+	postrack    uint64
+}
+
+func newViews(src source) *views {
+	return &views{src: src, scale: 1000000, cues: make(map[uint64][]cuepoint)}
+}
+
+func (v *views) HandleMasterBegin(id mkvparse.ElementID, info mkvparse.ElementInfo) (bool, error) {
+	switch id {
+	case mkvparse.SegmentElement:
+		v.segment = info.Offset
+	case mkvparse.ChapterAtomElement:
+		c := &chapter{}
+		v.chapters = append(v.chapters, c)
+		v.stack = append(v.stack, c)
+	case mkvparse.AttachedFileElement:
+		v.att = &attachment{}
+	case mkvparse.CuePointElement:
+		v.cuetime = 0
+	case mkvparse.CueTrackPositionsElement:
+		v.pos, v.postrack = &cuepoint{}, 0
+	}
+	return true, nil
+}
+
+func (v *views) HandleMasterEnd(id mkvparse.ElementID, info mkvparse.ElementInfo) error {
+	switch id {
+	case mkvparse.ChapterAtomElement:
+		if len(v.stack) > 0 {
+			v.stack = v.stack[:len(v.stack)-1]
+		}
+	case mkvparse.AttachedFileElement:
+		if v.att != nil && v.att.name != "" {
+			v.attachments = append(v.attachments, v.att)
+		}
+		v.att = nil
+	case mkvparse.CueTrackPositionsElement:
+		if v.pos != nil {
+			v.pos.time = v.cuetime
+			v.cues[v.postrack] = append(v.cues[v.postrack], *v.pos)
+		}
+		v.pos = nil
+	}
+	return nil
+}
+
+func (v *views) chapter() *chapter {
+	if len(v.stack) == 0 {
+		return nil
+	}
+	return v.stack[len(v.stack)-1]
+}
+
+func (v *views) HandleString(id mkvparse.ElementID, value string, info mkvparse.ElementInfo) error {
+	switch id {
+	case mkvparse.ChapStringElement:
+		//The first ChapterDisplay gives the title
+		if c := v.chapter(); c != nil && c.title == "" {
+			c.title = value
+		}
+	case mkvparse.FileNameElement:
+		if v.att != nil {
+			v.att.name = value
+		}
+	case mkvparse.FileMimeTypeElement:
+		if v.att != nil {
+			v.att.mime = value
+		}
+	case mkvparse.FileDescriptionElement:
+		if v.att != nil {
+			v.att.desc = value
+		}
+	}
+	return nil
+}
+
+func (v *views) HandleInteger(id mkvparse.ElementID, value int64, info mkvparse.ElementInfo) error {
+	switch id {
+	case mkvparse.TimecodeScaleElement:
+		if value > 0 {
+			v.scale = value
+		}
+	case mkvparse.ChapterTimeStartElement:
+		if c := v.chapter(); c != nil {
+			c.start = value
+		}
+	case mkvparse.ChapterTimeEndElement:
+		if c := v.chapter(); c != nil {
+			c.end, c.hasEnd = value, true
+		}
+	case mkvparse.CueTimeElement:
+		v.cuetime = value
+	case mkvparse.CueTrackElement:
+		v.postrack = uint64(value)
+	case mkvparse.CueClusterPositionElement:
+		if v.pos != nil {
+			v.pos.cluster = v.segment + value
+		}
+	}
+	return nil
+}
+
+func (v *views) HandleBinary(id mkvparse.ElementID, value []byte, info mkvparse.ElementInfo) error {
+	if id == mkvparse.FileDataElement && v.att != nil {
+		v.att.off, v.att.size = info.Offset, info.Size
+	}
+	return nil
+}
+
+//dirs builds the chapters, attachments and cues directories.
+//
+//Chapters are numbered in the order they appear, nested ones
+//following their parent, each file holds the start, end and
+//title of its chapter. Attachments are read from the loaded file
+//under their own names, the index file lists their MIME types.
+//Cues has a file for every track listing its seek points,
+//one per line as time and the offset of the Cluster element.
+func (v *views) dirs() []*fsutil.Dir {
+	chapters := fsutil.CreateDir("chapters")
+	width := len(strconv.Itoa(len(v.chapters)))
+	for i, c := range v.chapters {
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "start\t%s\n", formatClock(c.start))
+		if c.hasEnd {
+			fmt.Fprintf(&sb, "end\t%s\n", formatClock(c.end))
+		}
+		fmt.Fprintf(&sb, "title\t%s\n", c.title)
+		chapters.Append(fsutil.CreateFile([]byte(sb.String()), 0444, fmt.Sprintf("%0*d", width, i+1)).Stats)
+	}
+
+	index := fsutil.CreateFile([]byte{}, 0444, "index")
+	attachments := fsutil.CreateDir("attachments", index.Stats)
+	var sb strings.Builder
+	for _, a := range v.attachments {
+		name := attachments.UniqueName(a.name)
+		attachments.Append(&rawfile{v.src, name, a.off, a.size})
+		fmt.Fprintf(&sb, "%s\t%s\t%d\t%s\n", name, a.mime, a.size, a.desc)
+	}
+	index.WriteAt([]byte(sb.String()), 0)
+
+	cues := fsutil.CreateSortedDir("cues")
+	for track, points := range v.cues {
+		sort.SliceStable(points, func(i, j int) bool { return points[i].time < points[j].time })
+		var sb strings.Builder
+		for _, p := range points {
+			fmt.Fprintf(&sb, "%s\t%d\n", formatClock(p.time*v.scale), p.cluster)
+		}
+		cues.Append(fsutil.CreateFile([]byte(sb.String()), 0444, strconv.FormatUint(track, 10)).Stats)
+	}
+	return []*fsutil.Dir{chapters, attachments, cues}
+}
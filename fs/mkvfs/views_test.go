@@ -0,0 +1,45 @@
+package mkvfs
+
+import "testing"
+
+func TestViews(t *testing.T) {
+	mkv := el([]byte{0x18, 0x53, 0x80, 0x67},
+		el([]byte{0x10, 0x43, 0xa7, 0x70},
+			el([]byte{0x45, 0xb9},
+				el([]byte{0xb6},
+					el([]byte{0x91}, []byte{0}),
+					el([]byte{0x92}, []byte{0x01, 0x2a, 0x05, 0xf2, 0x00}),
+					el([]byte{0x80}, el([]byte{0x85}, []byte("Intro"))),
+					el([]byte{0xb6},
+						el([]byte{0x91}, []byte{0x3b, 0x9a, 0xca, 0x00}),
+						el([]byte{0x80}, el([]byte{0x85}, []byte("Sub"))))))),
+		el([]byte{0x19, 0x41, 0xa4, 0x69},
+			el([]byte{0x61, 0xa7},
+				el([]byte{0x46, 0x6e}, []byte("font.ttf")),
+				el([]byte{0x46, 0x60}, []byte("font/ttf")),
+				el([]byte{0x46, 0x5c}, []byte("FONT")))),
+		el([]byte{0x1c, 0x53, 0xbb, 0x6b},
+			el([]byte{0xbb},
+				el([]byte{0xb3}, []byte{0x03, 0xe8}),
+				el([]byte{0xb7},
+					el([]byte{0xf7}, []byte{1}),
+					el([]byte{0xf1}, []byte{0x10})))))
+	m, done := loadBytes(t, mkv)
+	defer done()
+	tests := []struct {
+		fpath  string
+		expect string
+	}{
+		{"chapters/1", "start\t00:00:00.000\nend\t00:00:05.000\ntitle\tIntro\n"},
+		{"chapters/2", "start\t00:00:01.000\ntitle\tSub\n"},
+		{"attachments/font.ttf", "FONT"},
+		{"attachments/index", "font.ttf\tfont/ttf\t4\t\n"},
+		//The Segment's data starts after its 4 byte ID and 8 byte size
+		{"cues/1", "00:00:01.000\t28\n"},
+	}
+	for _, test := range tests {
+		if s := readPath(t, m, test.fpath); s != test.expect {
+			t.Fatalf("expected %q got %q for %s", test.expect, s, test.fpath)
+		}
+	}
+}
@@ -14,6 +14,7 @@ import (
 type Pastefs struct {
 	newpaste *fsutil.File
 	pastes   *fsutil.Dir
+	quota    *fsutil.Quota
 }
 
 func dir2html(w io.Writer, dir *fsutil.Dir) (err error) {
@@ -33,7 +34,21 @@ func (fs *Pastefs) root() *fsutil.Dir {
 	return fsutil.CreateDir("/", d, fi)
 }
 
+//reap unlinks the pastes evicted by the quota since the last request.
+func (fs *Pastefs) reap() {
+	if fs.quota == nil {
+		return
+	}
+	for _, fi := range fs.quota.Evicted() {
+		//The name may have been taken again since
+		if cur, err := fs.pastes.Find(fi.Name()); err == nil && cur == fi {
+			fs.pastes.Remove(fi.Name())
+		}
+	}
+}
+
 func (fs *Pastefs) Stat(file string) (os.FileInfo, error) {
+	fs.reap()
 	switch file {
 	case "/":
 		return fs.root().Stat()
@@ -45,6 +60,7 @@ func (fs *Pastefs) Stat(file string) (os.FileInfo, error) {
 }
 
 func (fs *Pastefs) ReadDir(path string) (ffs.Dir, error) {
+	fs.reap()
 	switch path {
 	case "/":
 		return fs.root(), nil
@@ -54,6 +70,7 @@ func (fs *Pastefs) ReadDir(path string) (ffs.Dir, error) {
 }
 
 func (fs *Pastefs) Open(file string, mode int) (ffs.File, error) {
+	fs.reap()
 	switch file {
 	case "/index.html":
 		f := fsutil.CreateFile([]byte(""), 0644, "/index.html")
@@ -61,20 +78,33 @@ func (fs *Pastefs) Open(file string, mode int) (ffs.File, error) {
 		return f, err
 	case "/new":
 		if mode&os.O_RDWR != 0 || mode&os.O_WRONLY != 0 || mode&os.O_TRUNC != 0 {
-			name := strconv.FormatInt(time.Now().Unix(), 10)
+			name := fs.pastes.UniqueName(strconv.FormatInt(time.Now().Unix(), 10))
 			f := fsutil.CreateFile([]byte(name), 0777, name)
+			if fs.quota != nil {
+				if err := fs.quota.Track(f); err != nil {
+					return nil, err
+				}
+			}
 			fi, _ := f.Stat()
 			fs.pastes.Append(fi)
 			return f, nil
 		}
-		return fs.newpaste.Dup(), nil
+		return fs.newpaste.Open(os.O_RDONLY)
 	default:
-		return fs.root().WalkForFile(file)
+		return fs.root().OpenFile(file, mode)
 	}
 }
 
 func NewPastefs() *Pastefs {
-	return &Pastefs{fsutil.CreateFile([]byte(pastepage), 0777, "new"), fsutil.CreateDir("pastes")}
+	return &Pastefs{fsutil.CreateFile([]byte(pastepage), 0777, "new"), fsutil.CreateDir("pastes"), nil}
+}
+
+//SetQuota limits the space used by new pastes to q.
+//Pastes evicted by q are removed from the pastes directory
+//on the next request.
+func (fs *Pastefs) SetQuota(q *fsutil.Quota) {
+	q.OnEvict = nil
+	fs.quota = q
 }
 
 const pastepage = "Write to this file to paste\n"
@@ -145,3 +145,18 @@ func TestReadDir(t *testing.T) {
 		t.Fatal("content mismatch for pastes")
 	}
 }
+
+func TestQuota(t *testing.T) {
+	fs := NewPastefs()
+	q := fsutil.NewQuota(32, 0)
+	q.Evict = true
+	fs.SetQuota(q)
+	ts := httptest.NewServer(server.Server{Fs: fs})
+	defer ts.Close()
+
+	paste1 := ensurePaste(t, fs, ts.URL, strings.Repeat("a", 20))
+	ensurePaste(t, fs, ts.URL, strings.Repeat("b", 20))
+	if _, err := fs.pastes.Find(paste1); err != os.ErrNotExist {
+		t.Fatalf("expected %v got %v for evicted paste", os.ErrNotExist, err)
+	}
+}
@@ -14,14 +14,36 @@ import (
 type Ramfs struct {
 	sync.RWMutex
 	Root *fsutil.Dir
+	//Quota, if set, limits the space used by created files.
+	//Files it evicts are unlinked on the next request,
+	//so its OnEvict must be left unset.
+	Quota *fsutil.Quota
+	//parents of the files tracked by Quota
+	parents map[os.FileInfo]*fsutil.Dir
 }
 
 var DirExists = errors.New("File exists already as dir")
 var FileExists = errors.New("Dir exists already as file")
 
+//reap unlinks the files evicted by the quota, r must be locked.
+func (r *Ramfs) reap() {
+	if r.Quota == nil {
+		return
+	}
+	for _, fi := range r.Quota.Evicted() {
+		if d, ok := r.parents[fi]; ok {
+			if cur, err := d.Find(fi.Name()); err == nil && cur == fi {
+				d.Remove(fi.Name())
+			}
+			delete(r.parents, fi)
+		}
+	}
+}
+
 func (r *Ramfs) FindOrCreate(file string, isDir bool) (ffs.File, ffs.Dir, error) {
 	r.Lock()
 	defer r.Unlock()
+	r.reap()
 	dir := r.Root
 	parts := strings.Split(path.Clean(file), "/")
 	if len(parts) > 1 {
@@ -58,6 +80,15 @@ func (r *Ramfs) FindOrCreate(file string, isDir bool) (ffs.File, ffs.Dir, error)
 		return nil, d, nil
 	default:
 		f := fsutil.CreateFile([]byte{}, 0644, parts[len(parts)-1])
+		if r.Quota != nil {
+			if err := r.Quota.Track(f); err != nil {
+				return nil, nil, err
+			}
+			if r.parents == nil {
+				r.parents = make(map[os.FileInfo]*fsutil.Dir)
+			}
+			r.parents[f.Stats] = dir
+		}
 		dir.Append(f.Stats)
 		return f, nil, nil
 	}
@@ -65,7 +96,14 @@ func (r *Ramfs) FindOrCreate(file string, isDir bool) (ffs.File, ffs.Dir, error)
 
 func (r *Ramfs) Open(path string, mode int) (ffs.File, error) {
 	f, _, err := r.FindOrCreate(path, false)
-	return f, err
+	if err != nil {
+		return nil, err
+	}
+	//Every open gets its own handle, with its own offset and mode
+	if f, ok := f.(*fsutil.File); ok {
+		return f.Open(mode)
+	}
+	return f, nil
 }
 
 func (r *Ramfs) ReadDir(path string) (ffs.Dir, error) {
@@ -74,6 +112,9 @@ func (r *Ramfs) ReadDir(path string) (ffs.Dir, error) {
 }
 
 func (r *Ramfs) Stat(file string) (os.FileInfo, error) {
+	r.Lock()
+	r.reap()
+	r.Unlock()
 	//If we are stating something that doesn't exist we assume a file
 	fi, err := r.Root.Walk(file)
 	if err != nil {
@@ -4,6 +4,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/majiru/ffs"
 	"github.com/majiru/ffs/pkg/fsutil"
 )
 
@@ -35,7 +36,7 @@ const m1 = "Hello World"
 
 func TestOpenExisting(t *testing.T) {
 	ramfs := Ramfs{Root: fsutil.CreateDir("/")}
-	f, err := ramfs.Open("afile", 0644)
+	f, err := ramfs.Open("afile", os.O_RDWR)
 	if err != nil {
 		t.Fatal("Error opening file:", err)
 	}
@@ -45,7 +46,7 @@ func TestOpenExisting(t *testing.T) {
 	}
 	w.Write([]byte(m1))
 	f.Close()
-	f, err = ramfs.Open("afile", 0644)
+	f, err = ramfs.Open("afile", os.O_RDONLY)
 	if err != nil {
 		t.Fatal("Error opening file:", err)
 	}
@@ -56,6 +57,30 @@ func TestOpenExisting(t *testing.T) {
 	}
 }
 
+func TestOpenMode(t *testing.T) {
+	ramfs := Ramfs{Root: fsutil.CreateDir("/")}
+	f, err := ramfs.Open("afile", os.O_RDONLY)
+	if err != nil {
+		t.Fatal("Error opening file:", err)
+	}
+	if _, err = f.(*fsutil.File).Write([]byte(m1)); err != fsutil.ErrReadOnly {
+		t.Fatalf("expected %v got %v for write to read only file", fsutil.ErrReadOnly, err)
+	}
+	f, err = ramfs.Open("afile", os.O_WRONLY|os.O_APPEND)
+	if err != nil {
+		t.Fatal("Error opening file:", err)
+	}
+	w := f.(*fsutil.File)
+	w.Write([]byte(m1))
+	w.WriteAt([]byte(m1), 0)
+	if w.Size() != int64(2*len(m1)) {
+		t.Fatalf("expected %d got %d for size after appends", 2*len(m1), w.Size())
+	}
+	if _, err = w.Read(make([]byte, 1)); err != fsutil.ErrWriteOnly {
+		t.Fatalf("expected %v got %v for read of write only file", fsutil.ErrWriteOnly, err)
+	}
+}
+
 func TestStat(t *testing.T) {
 	ramfs := Ramfs{Root: fsutil.CreateDir("/")}
 	_, err := ramfs.Open("afile", 0644)
@@ -132,4 +157,26 @@ func TestFindOrCreateErr(t *testing.T) {
 	if err != DirExists {
 		t.Fatalf("expected %v got %v for file alread existing as dir", DirExists, err)
 	}
-}
\ No newline at end of file
+}
+
+func TestQuotaEvict(t *testing.T) {
+	q := fsutil.NewQuota(10, 0)
+	q.Evict = true
+	ramfs := Ramfs{Root: fsutil.CreateDir("/"), Quota: q}
+	for _, name := range []string{"/a", "/b"} {
+		f, err := ramfs.Open(name, os.O_RDWR)
+		if err != nil {
+			t.Fatal("Error opening file:", err)
+		}
+		if _, err = f.(ffs.Writer).Write([]byte("123456")); err != nil {
+			t.Fatal("Error writing file:", err)
+		}
+		f.Close()
+	}
+	if _, err := ramfs.Stat("/a"); err != os.ErrNotExist {
+		t.Fatalf("expected %v got %v for evicted file", os.ErrNotExist, err)
+	}
+	if _, err := ramfs.Stat("/b"); err != nil {
+		t.Fatal("Error stating kept file:", err)
+	}
+}
@@ -1,11 +1,18 @@
 package chanfile
 
 import (
+	"context"
+	"errors"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/majiru/ffs/pkg/fsutil"
 )
 
+var ErrTimeout = errors.New("chanfile: request timed out")
+var ErrHungup = errors.New("chanfile: file server has hung up")
+
 const (
 	//ReqMsg
 	Read = iota
@@ -31,20 +38,55 @@ type RecvMsg struct {
 	Err  error
 }
 
+//hangup is shared by every handle of a File.
+type hangup struct {
+	once sync.Once
+	done chan struct{}
+	//owed counts the replies the server still owes
+	//to requests that were given up on
+	mu   sync.Mutex
+	owed int
+}
+
+func (h *hangup) owe() {
+	h.mu.Lock()
+	h.owed++
+	h.mu.Unlock()
+}
+
+//stale reports whether a reply is owed to a request given up on,
+//if so the reply is taken to be that one.
+func (h *hangup) stale() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.owed > 0 {
+		h.owed--
+		return true
+	}
+	return false
+}
+
+func (h *hangup) owing() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.owed > 0
+}
+
+//File is served by a goroutine reading from Req and answering on Recv.
+//Every request waits at most Timeout for the server, a value of zero
+//waits forever. Requests are also cancelled with the context given to
+//SetContext. Once the server calls Hangup, requests return ErrHungup.
 type File struct {
 	Content *fsutil.File
 	Req     chan ReqMsg
 	Recv    chan RecvMsg
+	Timeout time.Duration
+	ctx     context.Context
+	h       *hangup
 }
 
 func CreateFile(content []byte, mode os.FileMode, name string) *File {
-	f := &File{
-		fsutil.CreateFile(content, mode, name),
-		make(chan ReqMsg),
-		make(chan RecvMsg),
-	}
-	f.Content.Stats.File = f
-	return f
+	return WrapFile(fsutil.CreateFile(content, mode, name))
 }
 
 func WrapFile(f *fsutil.File) *File {
@@ -52,20 +94,90 @@ func WrapFile(f *fsutil.File) *File {
 		f,
 		make(chan ReqMsg),
 		make(chan RecvMsg),
+		0,
+		context.Background(),
+		&hangup{done: make(chan struct{})},
 	}
 	chanf.Content.Stats.File = chanf
 	return chanf
 }
 
 func (f *File) Dup() *File {
-	return &File{f.Content.Dup(), f.Req, f.Recv}
+	return &File{f.Content.Dup(), f.Req, f.Recv, f.Timeout, f.ctx, f.h}
+}
+
+//SetContext cancels requests on this handle when ctx is done.
+//The server sets it to the context of the client's connection.
+func (f *File) SetContext(ctx context.Context) {
+	f.ctx = ctx
+}
+
+//Hangup shuts down the file, it is called by the server when
+//it stops serving requests. Waiting and future requests return ErrHungup.
+func (f *File) Hangup() {
+	f.h.once.Do(func() { close(f.h.done) })
+}
+
+//Done is closed after Hangup, server loops should select on it.
+func (f *File) Done() <-chan struct{} {
+	return f.h.done
+}
+
+//roundtrip sends m to the server and waits for the reply,
+//the error of the reply is returned as err.
+func (f *File) roundtrip(m ReqMsg) (RecvMsg, error) {
+	var timeout <-chan time.Time
+	if f.Timeout > 0 {
+		t := time.NewTimer(f.Timeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+	//A server late to answer an earlier request
+	//sends that reply before taking ours
+	for sent := false; !sent; {
+		var late <-chan RecvMsg
+		if f.h.owing() {
+			late = f.Recv
+		}
+		select {
+		case f.Req <- m:
+			sent = true
+		case <-late:
+			f.h.stale()
+		case <-f.h.done:
+			return RecvMsg{}, ErrHungup
+		case <-f.ctx.Done():
+			return RecvMsg{}, f.ctx.Err()
+		case <-timeout:
+			return RecvMsg{}, ErrTimeout
+		}
+	}
+	var err error
+	for err == nil {
+		select {
+		case r := <-f.Recv:
+			if f.h.stale() {
+				continue
+			}
+			return r, r.Err
+		case <-f.h.done:
+			return RecvMsg{}, ErrHungup
+		case <-f.ctx.Done():
+			err = f.ctx.Err()
+		case <-timeout:
+			err = ErrTimeout
+		}
+	}
+	//The server still owes us a reply, it is discarded by the
+	//next request instead of by a goroutine that could wait forever.
+	f.h.owe()
+	return RecvMsg{}, err
 }
 
 func (f *File) Write(b []byte) (int, error) {
-	f.Req <- ReqMsg{Write, f.Content.SeekPos(), int64(len(b)), b}
-	m := <-f.Recv
-	if m.Err != nil {
-		return 0, m.Err
+	m, err := f.roundtrip(ReqMsg{Write, f.Content.SeekPos(), int64(len(b)), b})
+	if err != nil {
+		return 0, err
 	}
 	if m.Type == Commit {
 		return f.Content.Write(b)
@@ -74,10 +186,9 @@ func (f *File) Write(b []byte) (int, error) {
 }
 
 func (f *File) WriteAt(b []byte, off int64) (int, error) {
-	f.Req <- ReqMsg{Write, off, int64(len(b)), b}
-	m := <-f.Recv
-	if m.Err != nil {
-		return 0, m.Err
+	m, err := f.roundtrip(ReqMsg{Write, off, int64(len(b)), b})
+	if err != nil {
+		return 0, err
 	}
 	if m.Type == Commit {
 		return f.Content.WriteAt(b, off)
@@ -86,10 +197,9 @@ func (f *File) WriteAt(b []byte, off int64) (int, error) {
 }
 
 func (f *File) Truncate(size int64) error {
-	f.Req <- ReqMsg{Trunc, 0, size, nil}
-	m := <-f.Recv
-	if m.Err != nil {
-		return m.Err
+	m, err := f.roundtrip(ReqMsg{Trunc, 0, size, nil})
+	if err != nil {
+		return err
 	}
 	if m.Type == Commit {
 		return f.Content.Truncate(size)
@@ -98,10 +208,9 @@ func (f *File) Truncate(size int64) error {
 }
 
 func (f *File) Read(b []byte) (int, error) {
-	f.Req <- ReqMsg{Read, f.Content.SeekPos(), int64(len(b)), nil}
-	m := <-f.Recv
-	if m.Err != nil {
-		return 0, m.Err
+	m, err := f.roundtrip(ReqMsg{Read, f.Content.SeekPos(), int64(len(b)), nil})
+	if err != nil {
+		return 0, err
 	}
 	if m.Type == Commit {
 		return f.Content.Read(b)
@@ -110,10 +219,9 @@ func (f *File) Read(b []byte) (int, error) {
 }
 
 func (f *File) ReadAt(b []byte, off int64) (int, error) {
-	f.Req <- ReqMsg{Read, off, int64(len(b)), nil}
-	m := <-f.Recv
-	if m.Err != nil {
-		return 0, m.Err
+	m, err := f.roundtrip(ReqMsg{Read, off, int64(len(b)), nil})
+	if err != nil {
+		return 0, err
 	}
 	if m.Type == Commit {
 		return f.Content.ReadAt(b, off)
@@ -130,11 +238,13 @@ func (f *File) Stat() (os.FileInfo, error) {
 	return f.Content.Stats, nil
 }
 
+//Close releases the handle even if the server fails the request.
+//Closing a handle after Hangup is not an error.
 func (f *File) Close() error {
-	f.Req <- ReqMsg{Close, 0, 0, nil}
-	m := <-f.Recv
-	if m.Err != nil {
-		return m.Err
+	_, err := f.roundtrip(ReqMsg{Close, 0, 0, nil})
+	cerr := f.Content.Close()
+	if err != nil && err != ErrHungup {
+		return err
 	}
-	return f.Content.Close()
+	return cerr
 }
\ No newline at end of file
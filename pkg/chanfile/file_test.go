@@ -1,8 +1,11 @@
 package chanfile
 
 import (
+	"context"
 	"io"
+	"runtime"
 	"testing"
+	"time"
 )
 
 func basicfileproc(f *File, n int) {
@@ -102,4 +105,103 @@ func TestReadWrite(t *testing.T) {
 	if string(m1) != string(b) {
 		t.Fatal("content mismatch")
 	}
-}
\ No newline at end of file
+}
+
+func TestTimeout(t *testing.T) {
+	f := CreateFile(m1, 0644, "test")
+	f.Timeout = 10 * time.Millisecond
+	//Nobody is serving the file
+	if _, err := f.Read(make([]byte, 1)); err != ErrTimeout {
+		t.Fatalf("expected %v got %v", ErrTimeout, err)
+	}
+	//A server that takes too long to reply
+	go func() {
+		<-f.Req
+		time.Sleep(50 * time.Millisecond)
+		f.Recv <- RecvMsg{Discard, nil}
+		basicfileproc(f, 1)
+	}()
+	if _, err := f.Read(make([]byte, 1)); err != ErrTimeout {
+		t.Fatalf("expected %v got %v", ErrTimeout, err)
+	}
+	//The late reply must not be handed to the next request
+	f.Timeout = 0
+	b := make([]byte, len(m1))
+	if n, err := f.ReadAt(b, 0); err != nil || n != len(m1) {
+		t.Fatalf("expected %d <nil> got %d %v", len(m1), n, err)
+	}
+}
+
+//A server that never replies nor hangs up must not
+//leave anything waiting behind timed out requests.
+func TestTimeoutLeak(t *testing.T) {
+	f := CreateFile(m1, 0644, "test")
+	f.Timeout = time.Millisecond
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-f.Req:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	f.Read(make([]byte, 1))
+	n := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		if _, err := f.Read(make([]byte, 1)); err != ErrTimeout {
+			t.Fatalf("expected %v got %v", ErrTimeout, err)
+		}
+	}
+	if g := runtime.NumGoroutine(); g > n {
+		t.Fatalf("%d goroutines left behind", g-n)
+	}
+}
+
+func TestContext(t *testing.T) {
+	f := CreateFile(m1, 0644, "test")
+	ctx, cancel := context.WithCancel(context.Background())
+	f.SetContext(ctx)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if _, err := f.Write(m1); err != context.Canceled {
+		t.Fatalf("expected %v got %v", context.Canceled, err)
+	}
+}
+
+func TestHangup(t *testing.T) {
+	f := CreateFile(m1, 0644, "test")
+	exited := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-f.Req:
+				f.Recv <- RecvMsg{Commit, nil}
+			case <-f.Done():
+				close(exited)
+				return
+			}
+		}
+	}()
+	dup := f.Dup()
+	if _, err := dup.Read(make([]byte, 1)); err != nil {
+		t.Fatal("error reading chanfile:", err)
+	}
+	f.Hangup()
+	f.Hangup()
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		t.Fatal("server loop did not exit on Hangup")
+	}
+	if _, err := dup.Read(make([]byte, 1)); err != ErrHungup {
+		t.Fatalf("expected %v got %v", ErrHungup, err)
+	}
+	if err := dup.Close(); err != nil {
+		t.Fatal("Close after Hangup returned error:", err)
+	}
+}
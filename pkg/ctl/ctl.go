@@ -0,0 +1,184 @@
+//Package ctl implements Plan 9 style control files.
+//
+//Each line written to a ctl file is split into a command and its
+//arguments and dispatched to the handler registered for the command.
+//Reading a ctl file returns its status followed by help for every command.
+package ctl
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/majiru/ffs/pkg/fsutil"
+	"github.com/majiru/ffs/pkg/synthfile"
+)
+
+var ErrUnknown = errors.New("ctl: unknown command")
+var ErrQuote = errors.New("ctl: unterminated quote")
+
+//Usage is returned when a command is given the wrong arguments.
+type Usage string
+
+func (u Usage) Error() string { return "usage: " + string(u) }
+
+//Cmd describes a command accepted by a Ctl.
+//Args names the arguments for help and usage messages.
+//Fn is only called with between MinArgs and MaxArgs arguments,
+//a negative MaxArgs allows any number.
+type Cmd struct {
+	Name    string
+	Args    string
+	Help    string
+	MinArgs int
+	MaxArgs int
+	Fn      func(args []string) error
+}
+
+func (c *Cmd) usage() Usage {
+	if c.Args == "" {
+		return Usage(c.Name)
+	}
+	return Usage(c.Name + " " + c.Args)
+}
+
+//Ctl is a control file.
+//Status, if set, is called for the first part of the text read from the file.
+type Ctl struct {
+	sync.RWMutex
+	*synthfile.File
+	Status func() string
+	cmds   map[string]*Cmd
+}
+
+//New creates a new Ctl file with the given name and mode.
+//The underlying Stats.Sys() points to the synthfile.File.
+func New(name string, mode os.FileMode) *Ctl {
+	c := &Ctl{cmds: make(map[string]*Cmd)}
+	c.File = synthfile.CreateFile([]byte{}, mode, name, synthfile.Handler{
+		OnOpen: func(h *synthfile.Handle) error {
+			h.Content = fsutil.CreateFile(c.Text(), 0444, name)
+			return nil
+		},
+		OnWrite: func(h *synthfile.Handle, b []byte, off int64) (int, error) {
+			if err := c.Exec(string(b)); err != nil {
+				return 0, err
+			}
+			//Let the writer read back the new status
+			h.Content = fsutil.CreateFile(c.Text(), 0444, name)
+			return len(b), nil
+		},
+		OnTruncate: func(h *synthfile.Handle, size int64) error {
+			return nil
+		},
+	})
+	return c
+}
+
+//Register adds cmd, replacing any command of the same name.
+func (c *Ctl) Register(cmd Cmd) {
+	c.Lock()
+	c.cmds[cmd.Name] = &cmd
+	c.Unlock()
+}
+
+//Exec runs each line of s as a command, stopping at the first error.
+//Empty lines and lines starting with # are skipped.
+func (c *Ctl) Exec(s string) error {
+	for _, line := range strings.Split(s, "\n") {
+		args, err := Tokenize(line)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 || strings.HasPrefix(args[0], "#") {
+			continue
+		}
+		c.RLock()
+		cmd, ok := c.cmds[args[0]]
+		c.RUnlock()
+		if !ok {
+			return ErrUnknown
+		}
+		args = args[1:]
+		if len(args) < cmd.MinArgs || (cmd.MaxArgs >= 0 && len(args) > cmd.MaxArgs) {
+			return cmd.usage()
+		}
+		if err = cmd.Fn(args); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Text returns what is read from the file, the status and then the help.
+func (c *Ctl) Text() []byte {
+	var b bytes.Buffer
+	if c.Status != nil {
+		b.WriteString(c.Status())
+		if b.Len() > 0 && b.Bytes()[b.Len()-1] != '\n' {
+			b.WriteByte('\n')
+		}
+	}
+	c.RLock()
+	names := make([]string, 0, len(c.cmds))
+	for name := range c.cmds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd := c.cmds[name]
+		b.WriteString(string(cmd.usage()))
+		if cmd.Help != "" {
+			b.WriteString("\t" + cmd.Help)
+		}
+		b.WriteByte('\n')
+	}
+	c.RUnlock()
+	return b.Bytes()
+}
+
+//Tokenize splits line into fields separated by white space.
+//As with rc, single quotes group a field and
+//a doubled single quote inside them stands for one quote.
+func Tokenize(line string) (out []string, err error) {
+	var (
+		field   strings.Builder
+		quoted  bool
+		infield bool
+	)
+	for i := 0; i < len(line); i++ {
+		r := line[i]
+		switch {
+		case quoted && r == '\'':
+			if i+1 < len(line) && line[i+1] == '\'' {
+				field.WriteByte('\'')
+				i++
+			} else {
+				quoted = false
+			}
+		case quoted:
+			field.WriteByte(r)
+		case r == '\'':
+			quoted, infield = true, true
+		case r == ' ' || r == '\t' || r == '\r' || r == '\n':
+			if infield {
+				out = append(out, field.String())
+				field.Reset()
+				infield = false
+			}
+		default:
+			field.WriteByte(r)
+			infield = true
+		}
+	}
+	if quoted {
+		return nil, ErrQuote
+	}
+	if infield {
+		out = append(out, field.String())
+	}
+	return
+}
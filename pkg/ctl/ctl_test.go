@@ -0,0 +1,96 @@
+package ctl
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		line   string
+		expect []string
+		err    error
+	}{
+		{"", nil, nil},
+		{"load", []string{"load"}, nil},
+		{"  load\t/a/b  \n", []string{"load", "/a/b"}, nil},
+		{"load '/a b/c'", []string{"load", "/a b/c"}, nil},
+		{"echo 'it''s' x''y", []string{"echo", "it's", "xy"}, nil},
+		{"echo ''", []string{"echo", ""}, nil},
+		{"echo 'oops", nil, ErrQuote},
+	}
+	for _, test := range tests {
+		out, err := Tokenize(test.line)
+		if err != test.err {
+			t.Fatalf("expected %v got %v for %q", test.err, err, test.line)
+		}
+		if strings.Join(out, "|") != strings.Join(test.expect, "|") || len(out) != len(test.expect) {
+			t.Fatalf("expected %q got %q for %q", test.expect, out, test.line)
+		}
+	}
+}
+
+func testCtl(got *[]string) *Ctl {
+	c := New("ctl", 0644)
+	c.Register(Cmd{"load", "path", "load a file", 1, 1, func(args []string) error {
+		*got = append(*got, args[0])
+		return nil
+	}})
+	c.Register(Cmd{"reset", "", "forget everything", 0, 0, func(args []string) error {
+		*got = nil
+		return nil
+	}})
+	c.Status = func() string { return "loaded " + strings.Join(*got, " ") }
+	return c
+}
+
+func TestExec(t *testing.T) {
+	var got []string
+	c := testCtl(&got)
+	if err := c.Exec("load a\n\n# comment\nload 'b c'\n"); err != nil {
+		t.Fatal("Exec returned error:", err)
+	}
+	if strings.Join(got, "|") != "a|b c" {
+		t.Fatalf("expected %v got %v", []string{"a", "b c"}, got)
+	}
+	for _, test := range []struct {
+		line string
+		err  string
+	}{
+		{"load", "usage: load path"},
+		{"load a b", "usage: load path"},
+		{"reset now", "usage: reset"},
+		{"unload a", ErrUnknown.Error()},
+	} {
+		if err := c.Exec(test.line); err == nil || err.Error() != test.err {
+			t.Fatalf("expected %s got %v for %q", test.err, err, test.line)
+		}
+	}
+}
+
+func TestFile(t *testing.T) {
+	var got []string
+	c := testCtl(&got)
+	h, err := c.Open(os.O_RDWR)
+	if err != nil {
+		t.Fatal("error opening ctl:", err)
+	}
+	b, _ := ioutil.ReadAll(h)
+	expect := "loaded \nload path\tload a file\nreset\tforget everything\n"
+	if string(b) != expect {
+		t.Fatalf("expected %q got %q", expect, b)
+	}
+	if _, err = h.Write([]byte("load x")); err != nil {
+		t.Fatal("Write returned error:", err)
+	}
+	if _, err = h.Write([]byte("load")); err == nil {
+		t.Fatal("expected usage error from Write")
+	}
+	b = make([]byte, 8)
+	h.ReadAt(b, 0)
+	if string(b) != "loaded x" {
+		t.Fatalf("expected %q got %q", "loaded x", b)
+	}
+}
@@ -4,16 +4,32 @@ import (
 	"errors"
 	"io"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
-	"time"
+	"sync"
 )
 
 var ErrCastFile = errors.New("cast to file failed")
 var ErrCastDir = errors.New("cast to dir failed")
 
+//dirnode holds the entries shared by every handle of a Dir.
+//Entries are indexed by name, so names within a Dir are unique.
+type dirnode struct {
+	sync.RWMutex
+	files  []os.FileInfo
+	index  map[string]int
+	sorted bool
+	dirty  bool
+	unique map[string]int
+}
+
 //Dir represents an in memory Directory.
+//Entries are listed in the order they were added,
+//or by name for directories made with CreateSortedDir.
+//A Dir is safe for concurrent use.
 type Dir struct {
-	files []os.FileInfo
+	*dirnode
 	i     int
 	Stats *Stat
 }
@@ -21,67 +37,195 @@ type Dir struct {
 //CreateDir creates a new Dir struct.
 //The underlying Stats.Sys() points to the new Dir.
 func CreateDir(name string, files ...os.FileInfo) *Dir {
-	d := Dir{files, 0, nil}
-	d.Stats = &Stat{os.ModeDir | 0777, name, time.Now(), 0, &d}
+	d := Dir{&dirnode{index: make(map[string]int)}, 0, nil}
+	d.Stats = newStat(os.ModeDir|0777, name, 0, &d)
+	d.Append(files...)
 	return &d
 }
 
+//CreateSortedDir creates a new Dir that lists its entries by name.
+func CreateSortedDir(name string, files ...os.FileInfo) *Dir {
+	d := CreateDir(name)
+	d.sorted = true
+	d.Append(files...)
+	return d
+}
+
+//list returns the current entries, sorting them first if needed.
+//The returned slice must not be modified.
+func (d *dirnode) list() []os.FileInfo {
+	d.RLock()
+	if !d.dirty {
+		defer d.RUnlock()
+		return d.files
+	}
+	d.RUnlock()
+	d.Lock()
+	defer d.Unlock()
+	if d.dirty {
+		//Sort a copy, readers may still hold the old slice
+		files := make([]os.FileInfo, len(d.files))
+		copy(files, d.files)
+		sort.SliceStable(files, func(i, j int) bool {
+			return files[i].Name() < files[j].Name()
+		})
+		d.files = files
+		for i, fi := range d.files {
+			d.index[fi.Name()] = i
+		}
+		d.dirty = false
+	}
+	return d.files
+}
+
 func (d *Dir) Readdir(n int) ([]os.FileInfo, error) {
+	files := d.list()
 	if n <= 0 {
-		return d.files, nil
+		return files, nil
 	}
-	if d.i >= len(d.files) {
+	if d.i >= len(files) {
 		return nil, io.EOF
 	}
 	start := d.i
-	if len(d.files) > d.i+n {
+	if len(files) > d.i+n {
 		d.i += n
 	} else {
-		d.i = len(d.files)
+		d.i = len(files)
 	}
-	return d.files[start:d.i], nil
+	return files[start:d.i], nil
 }
 
 func (d Dir) Stat() (os.FileInfo, error) {
 	return d.Stats, nil
 }
 
+//add must be called with d locked.
+func (d *dirnode) add(fi os.FileInfo) {
+	name := fi.Name()
+	if i, ok := d.index[name]; ok {
+		d.replace(i, fi)
+		return
+	}
+	//Appending only writes past the end of slices handed out by list,
+	//entries they can see are never changed in place.
+	d.index[name] = len(d.files)
+	d.files = append(d.files, fi)
+	if n := len(d.files); d.sorted && n > 1 && d.files[n-2].Name() > name {
+		d.dirty = true
+	}
+}
+
+//Append adds files to the directory.
+//A file with the same name as an existing entry replaces it.
 func (d *Dir) Append(files ...os.FileInfo) {
-	d.files = append(d.files, files...)
+	d.Lock()
+	for _, fi := range files {
+		d.add(fi)
+	}
+	d.Unlock()
+}
+
+//Remove unlinks the file specified by name.
+func (d *Dir) Remove(name string) error {
+	d.Lock()
+	defer d.Unlock()
+	i, ok := d.index[name]
+	if !ok {
+		return os.ErrNotExist
+	}
+	d.files = append(d.files[:i:i], d.files[i+1:]...)
+	delete(d.index, name)
+	for ; i < len(d.files); i++ {
+		d.index[d.files[i].Name()] = i
+	}
+	return nil
+}
+
+//Replace swaps the file specified by name for fi, keeping its place.
+//If fi has a different name, no other entry may already use it.
+func (d *Dir) Replace(name string, fi os.FileInfo) error {
+	d.Lock()
+	defer d.Unlock()
+	i, ok := d.index[name]
+	if !ok {
+		return os.ErrNotExist
+	}
+	if newname := fi.Name(); newname != name {
+		if _, ok := d.index[newname]; ok {
+			return os.ErrExist
+		}
+		delete(d.index, name)
+		d.index[newname] = i
+		if d.sorted {
+			d.dirty = true
+		}
+	}
+	d.replace(i, fi)
+	return nil
+}
+
+//replace must be called with d locked.
+func (d *dirnode) replace(i int, fi os.FileInfo) {
+	files := make([]os.FileInfo, len(d.files))
+	copy(files, d.files)
+	files[i] = fi
+	d.files = files
 }
 
 //Find performs a 1 level deep search to find a file specified by name
 func (d *Dir) Find(name string) (os.FileInfo, error) {
-	for _, dir := range d.files {
-		if dir.Name() == name {
-			return dir, nil
-		}
+	d.RLock()
+	defer d.RUnlock()
+	if i, ok := d.index[name]; ok {
+		return d.files[i], nil
 	}
 	return nil, os.ErrNotExist
 }
 
-func search(target string, files []os.FileInfo) (os.FileInfo, error) {
-	if len(files) == 0 {
-		return nil, os.ErrNotExist
+//UniqueName returns a name that is not yet used in the directory,
+//by appending an increasing number to base: base, base2, base3...
+//The name is not reserved, the caller should Append it before
+//asking for another.
+func (d *Dir) UniqueName(base string) string {
+	d.Lock()
+	defer d.Unlock()
+	if _, ok := d.index[base]; !ok {
+		return base
 	}
-	fi := files[0]
-	if fi.Name() == target {
-		return fi, nil
+	if d.unique == nil {
+		d.unique = make(map[string]int)
 	}
-	if fi.IsDir() {
-		if dir, ok := fi.Sys().(*Dir); ok {
-			if match, err := search(target, dir.files); err == nil {
-				return match, nil
-			}
+	i := d.unique[base]
+	if i < 2 {
+		i = 2
+	}
+	for {
+		name := base + strconv.Itoa(i)
+		if _, ok := d.index[name]; !ok {
+			d.unique[base] = i + 1
+			return name
 		}
+		i++
 	}
-	return search(target, files[1:])
 }
 
 //Search performs a recursive search into all subdirs looking for name.
 //recusrive descent is only possible if subdir is of type *Dir
 func (d *Dir) Search(name string) (os.FileInfo, error) {
-	return search(name, d.files)
+	for _, fi := range d.list() {
+		if fi.Name() == name {
+			return fi, nil
+		}
+		if !fi.IsDir() {
+			continue
+		}
+		if dir, ok := fi.Sys().(*Dir); ok {
+			if match, err := dir.Search(name); err == nil {
+				return match, nil
+			}
+		}
+	}
+	return nil, os.ErrNotExist
 }
 
 func split(fpath string) (clean []string) {
@@ -116,12 +260,18 @@ func (d *Dir) Walk(fpath string) (fi os.FileInfo, err error) {
 	return
 }
 
+//WalkForFile walks to fpath and opens a new read/write handle to it.
 func (d *Dir) WalkForFile(fpath string) (*File, error) {
+	return d.OpenFile(fpath, os.O_RDWR)
+}
+
+//OpenFile walks to fpath and opens a new handle to it with the given flag.
+func (d *Dir) OpenFile(fpath string, flag int) (*File, error) {
 	if fi, err := d.Walk(fpath); err != nil {
 		return nil, err
 	} else {
 		if f, ok := fi.Sys().(*File); ok {
-			return f.Dup(), nil
+			return f.Open(flag)
 		} else {
 			return nil, ErrCastFile
 		}
@@ -142,12 +292,14 @@ func (d *Dir) WalkForDir(fpath string) (*Dir, error) {
 
 //Copy duplicates the held file info slice to the caller.
 func (d *Dir) Copy() (out []os.FileInfo) {
-	out = make([]os.FileInfo, len(d.files))
-	copy(out, d.files)
+	files := d.list()
+	out = make([]os.FileInfo, len(files))
+	copy(out, files)
 	return
 }
 
-//Dup creates a new Dir, duplicatin all contained data.
+//Dup creates a new handle to the Dir,
+//sharing its entries but with its own Readdir position.
 func (d *Dir) Dup() *Dir {
 	new := *d
 	return &new
@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"path"
+	"sync"
 	"testing"
 )
 
@@ -69,6 +70,17 @@ func TestSearch(t *testing.T) {
 	}
 }
 
+func TestSearchOrder(t *testing.T) {
+	deep := CreateFile([]byte{}, 0644, "name")
+	d := CreateDir("/", CreateDir("sub", deep.Stats).Stats,
+		CreateFile([]byte{}, 0644, "name").Stats)
+	//Subdirs are searched as they are reached, depth first
+	fi, err := d.Search("name")
+	if err != nil || fi != os.FileInfo(deep.Stats) {
+		t.Fatalf("expected %v got %v %v", deep.Stats, fi, err)
+	}
+}
+
 func TestSearchEmpty(t *testing.T) {
 	d := CreateDir("/")
 	_, err := d.Search("chris")
@@ -185,4 +197,171 @@ func TestCopy(t *testing.T) {
 			t.Fatalf("expected %s got %s", names[i], fi[i].Name())
 		}
 	}
-}
\ No newline at end of file
+}
+func names(fi []os.FileInfo) (out []string) {
+	for _, f := range fi {
+		out = append(out, f.Name())
+	}
+	return
+}
+
+func TestSorted(t *testing.T) {
+	d := CreateSortedDir("/")
+	for _, n := range []string{"danny", "bliss", "test", "chris"} {
+		d.Append(CreateFile([]byte{}, 0644, n).Stats)
+	}
+	expect := []string{"bliss", "chris", "danny", "test"}
+	got := names(d.Copy())
+	if fmt.Sprint(got) != fmt.Sprint(expect) {
+		t.Fatalf("expected %v got %v", expect, got)
+	}
+	if err := d.Replace("test", CreateFile([]byte{}, 0644, "alice").Stats); err != nil {
+		t.Fatal("Replace returned error:", err)
+	}
+	expect = []string{"alice", "bliss", "chris", "danny"}
+	fi, _ := d.Readdir(-1)
+	if got = names(fi); fmt.Sprint(got) != fmt.Sprint(expect) {
+		t.Fatalf("expected %v got %v", expect, got)
+	}
+	if _, err := d.Find("alice"); err != nil {
+		t.Fatal("Find after rename returned error:", err)
+	}
+}
+
+func TestAppendReplaces(t *testing.T) {
+	d := CreateDir("/", CreateFile([]byte{}, 0644, "chris").Stats, CreateFile([]byte{}, 0644, "bliss").Stats)
+	new := CreateFile([]byte("new"), 0644, "chris")
+	d.Append(new.Stats)
+	fi := d.Copy()
+	if len(fi) != 2 || fi[0] != new.Stats {
+		t.Fatalf("expected chris to be replaced in place got %v", names(fi))
+	}
+}
+
+func TestRemove(t *testing.T) {
+	d := CreateDir("/")
+	for i := 0; i < FilesPerDir; i++ {
+		d.Append(CreateFile([]byte{}, 0644, fmt.Sprintf("test%d", i)).Stats)
+	}
+	before, _ := d.Readdir(-1)
+	if err := d.Remove("test5"); err != nil {
+		t.Fatal("Remove returned error:", err)
+	}
+	if err := d.Remove("test5"); err != os.ErrNotExist {
+		t.Fatalf("expected %v got %v", os.ErrNotExist, err)
+	}
+	if before[5].Name() != "test5" {
+		t.Fatal("Remove modified a previous listing")
+	}
+	for i := 0; i < FilesPerDir; i++ {
+		_, err := d.Find(fmt.Sprintf("test%d", i))
+		if i == 5 && err != os.ErrNotExist {
+			t.Fatal("found removed file")
+		} else if i != 5 && err != nil {
+			t.Fatalf("Find test%d after Remove: %v", i, err)
+		}
+	}
+	if fi := d.Copy(); len(fi) != FilesPerDir-1 || fi[5].Name() != "test6" {
+		t.Fatalf("unexpected listing after Remove: %v", names(fi))
+	}
+	if err := d.Replace("test5", CreateFile([]byte{}, 0644, "x").Stats); err != os.ErrNotExist {
+		t.Fatalf("expected %v got %v", os.ErrNotExist, err)
+	}
+	if err := d.Replace("test6", CreateFile([]byte{}, 0644, "test7").Stats); err != os.ErrExist {
+		t.Fatalf("expected %v got %v", os.ErrExist, err)
+	}
+}
+
+func TestUniqueName(t *testing.T) {
+	d := CreateDir("/")
+	expect := []string{"Block", "Block2", "Block3"}
+	for _, e := range expect {
+		n := d.UniqueName("Block")
+		if n != e {
+			t.Fatalf("expected %s got %s", e, n)
+		}
+		d.Append(CreateFile([]byte{}, 0644, n).Stats)
+	}
+}
+
+func TestDirConcur(t *testing.T) {
+	d := CreateSortedDir("/")
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				name := fmt.Sprintf("test%d-%d", i, j)
+				d.Append(CreateFile([]byte{}, 0644, name).Stats)
+				d.Copy()
+				if _, err := d.Find(name); err != nil {
+					t.Errorf("Find %s: %v", name, err)
+				}
+				if j%2 == 0 {
+					d.Remove(name)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if fi := d.Copy(); len(fi) != 200 {
+		t.Fatalf("expected 200 entries got %d", len(fi))
+	}
+}
+
+const benchEntries = 100000
+
+func benchDir(sorted bool) *Dir {
+	d := CreateDir("/")
+	if sorted {
+		d = CreateSortedDir("/")
+	}
+	for i := 0; i < benchEntries; i++ {
+		d.Append(CreateFile([]byte{}, 0644, fmt.Sprintf("file%d", (i*7919)%benchEntries)).Stats)
+	}
+	return d
+}
+
+func BenchmarkAppend100k(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		benchDir(false)
+	}
+}
+
+func BenchmarkSortedAppend100k(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		benchDir(true).Copy()
+	}
+}
+
+func BenchmarkFind100k(b *testing.B) {
+	d := benchDir(false)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.Find(fmt.Sprintf("file%d", i%benchEntries))
+	}
+}
+
+func BenchmarkReaddir100k(b *testing.B) {
+	d := benchDir(true)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dup := d.Dup()
+		for {
+			if _, err := dup.Readdir(100); err == io.EOF {
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkRemove100k(b *testing.B) {
+	d := benchDir(false)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		name := fmt.Sprintf("file%d", i%benchEntries)
+		d.Remove(name)
+		d.Append(CreateFile([]byte{}, 0644, name).Stats)
+	}
+}
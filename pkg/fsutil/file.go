@@ -2,56 +2,147 @@
 package fsutil
 
 import (
+	"context"
 	"errors"
 	"io"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/majiru/ffs"
 )
 
 var ErrNeg = errors.New("fsutil: negative offset")
 var ErrInvalWhence = errors.New("fsutil: invalid whence")
 var ErrBadCopy = errors.New("fsutil: bad copy")
+var ErrReadOnly = errors.New("fsutil: file not open for writing")
+var ErrWriteOnly = errors.New("fsutil: file not open for reading")
+
+//inode holds the state shared by every handle of a file.
+//Content is held in sparse pages, so growing a file
+//only allocates the parts that are written to.
+type inode struct {
+	sync.RWMutex
+	s     *pages
+	quota *Quota
+	atime int64
+}
 
-//File represents an in memory file.
+//File represents an open handle to an in memory file.
+//Each handle has its own seek position and open mode,
+//the content and stats are shared with every other handle of the file.
 type File struct {
-	*sync.RWMutex
-	s     *[]byte
-	i     int64
-	Stats *Stat
+	*inode
+	i      int64
+	flag   int
+	closed bool
+	Stats  *Stat
+	//user is who the handle was opened for, see SetContext
+	user string
 }
 
-//CreateFile creates a new File struct.
+//CreateFile creates a new File struct, opened for reading and writing.
 //The underlying Stats.Sys() points to the new File.
 func CreateFile(content []byte, mode os.FileMode, name string) *File {
-	f := File{&sync.RWMutex{}, &content, 0, nil}
-	f.Stats = &Stat{mode, name, time.Now(), int64(len(content)), &f}
+	f := File{&inode{s: newPages(content)}, 0, os.O_RDWR, false, nil, ""}
+	f.Stats = newStat(mode, name, int64(len(content)), &f)
+	f.atime = f.Stats.ModTime().UnixNano()
 	return &f
 }
 
-func (f File) Size() int64 { return int64(len(*f.s)) }
+//Open creates a new handle to the file, positioned at the start.
+//The access mode in flag is enforced on the new handle: O_RDONLY handles
+//refuse writes, O_WRONLY handles refuse reads and O_APPEND handles
+//always write to the end of the file. O_TRUNC truncates the file.
+func (f *File) Open(flag int) (*File, error) {
+	new := &File{f.inode, 0, flag, false, f.Stats, f.user}
+	if flag&os.O_TRUNC != 0 {
+		if err := new.Truncate(0); err != nil {
+			return nil, err
+		}
+	}
+	return new, nil
+}
 
-func (f *File) Grow(n int64) {
-	if int64(len(*f.s)) >= n {
-		return
+func (f File) Size() int64 { return f.s.size }
+
+//AccessTime returns the last time the file was read through any handle.
+func (f File) AccessTime() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&f.atime))
+}
+
+//access records a read, reads only hold the read lock
+//so the time is set atomically.
+func (f *File) access() {
+	atomic.StoreInt64(&f.atime, time.Now().UnixNano())
+	f.quota.touch(f.Stats)
+}
+
+func (f *File) canRead() error {
+	switch {
+	case f.closed:
+		return os.ErrClosed
+	case f.flag&(os.O_RDONLY|os.O_WRONLY|os.O_RDWR) == os.O_WRONLY:
+		return ErrWriteOnly
 	}
-	new := make([]byte, n)
-	copy(new, *f.s)
-	*f.s = new
-	return
+	return nil
+}
+
+func (f *File) canWrite() error {
+	switch {
+	case f.closed:
+		return os.ErrClosed
+	case f.flag&(os.O_RDONLY|os.O_WRONLY|os.O_RDWR) == os.O_RDONLY:
+		return ErrReadOnly
+	}
+	return nil
+}
+
+//Grow extends the file to n bytes.
+//The new space reads as zeros and is not allocated until written.
+//If the file is tracked by a Quota, ffs.ErrNoSpace is returned
+//when the new size does not fit.
+func (f *File) Grow(n int64) error {
+	if f.s.size >= n {
+		return nil
+	}
+	if f.quota != nil {
+		if err := f.quota.charge(f.Stats, n, f.s.held); err != nil {
+			return err
+		}
+	}
+	f.s.truncate(n)
+	return nil
+}
+
+//writeAt must be called with f locked.
+func (f *File) writeAt(b []byte, off int64) (int, error) {
+	if err := f.canWrite(); err != nil {
+		return 0, err
+	}
+	if f.flag&os.O_APPEND != 0 {
+		off = f.s.size
+	}
+	if f.quota != nil {
+		size := off + int64(len(b))
+		if size < f.s.size {
+			size = f.s.size
+		}
+		if err := f.quota.charge(f.Stats, size, f.s.held+f.s.need(off, int64(len(b)))); err != nil {
+			return 0, err
+		}
+	}
+	f.Stats.modified(f.user)
+	f.s.writeAt(b, off)
+	f.i = off + int64(len(b))
+	return len(b), nil
 }
 
 func (f *File) Write(b []byte) (n int, err error) {
 	f.Lock()
 	defer f.Unlock()
-	f.Stats.time = time.Now()
-	f.Grow(int64(len(b)) + f.i)
-	n = copy((*f.s)[f.i:], b)
-	if n < len(b) {
-		panic(ErrBadCopy)
-	}
-	f.i += int64(n)
-	return
+	return f.writeAt(b, f.i)
 }
 
 func (f *File) WriteAt(b []byte, off int64) (n int, err error) {
@@ -60,22 +151,24 @@ func (f *File) WriteAt(b []byte, off int64) (n int, err error) {
 	}
 	f.Lock()
 	defer f.Unlock()
-	f.Stats.time = time.Now()
-	f.Grow(int64(len(b)) + off)
-	n = copy((*f.s)[off:], b)
-	if n < len(b) {
-		panic(ErrBadCopy)
-	}
+	//WriteAt does not move the seek position
+	i := f.i
+	n, err = f.writeAt(b, off)
+	f.i = i
 	return
 }
 
 func (f *File) Read(b []byte) (n int, err error) {
 	f.RLock()
 	defer f.RUnlock()
-	if f.i >= int64(len(*f.s)) {
+	if err = f.canRead(); err != nil {
+		return
+	}
+	f.access()
+	if f.i >= f.s.size {
 		return 0, io.EOF
 	}
-	n = copy(b, (*f.s)[f.i:])
+	n = f.s.readAt(b, f.i)
 	f.i += int64(n)
 	return
 }
@@ -87,10 +180,14 @@ func (f *File) ReadAt(b []byte, off int64) (n int, err error) {
 	if off < 0 {
 		return 0, ErrNeg
 	}
-	if off >= int64(len(*f.s)) {
+	if err = f.canRead(); err != nil {
+		return
+	}
+	f.access()
+	if off >= f.s.size {
 		return 0, io.EOF
 	}
-	n = copy(b, (*f.s)[off:])
+	n = f.s.readAt(b, off)
 	if n < len(b) {
 		err = io.EOF
 	}
@@ -98,7 +195,10 @@ func (f *File) ReadAt(b []byte, off int64) (n int, err error) {
 }
 
 func (f *File) Seek(offset int64, whence int) (int64, error) {
-	//Each instance of a File has its own seekpos
+	//Each handle of a File has its own seekpos
+	if f.closed {
+		return 0, os.ErrClosed
+	}
 	var abs int64
 	switch whence {
 	case io.SeekStart:
@@ -106,7 +206,9 @@ func (f *File) Seek(offset int64, whence int) (int64, error) {
 	case io.SeekCurrent:
 		abs = f.i + offset
 	case io.SeekEnd:
-		abs = int64(len(*f.s)) + offset
+		f.RLock()
+		abs = f.s.size + offset
+		f.RUnlock()
 	default:
 		return 0, ErrInvalWhence
 	}
@@ -117,11 +219,24 @@ func (f *File) Seek(offset int64, whence int) (int64, error) {
 	return abs, nil
 }
 
+//Close releases the handle, further use of it returns os.ErrClosed.
+//Other handles of the file are not affected.
 func (f *File) Close() error {
-	f.i = 0
+	if f.closed {
+		return os.ErrClosed
+	}
+	f.closed = true
 	return nil
 }
 
+//SetContext takes the user writes through the handle are made by
+//from ctx, so they are recorded as the last to modify the file.
+func (f *File) SetContext(ctx context.Context) {
+	if user, ok := ctx.Value(ffs.UserKey{}).(string); ok {
+		f.user = user
+	}
+}
+
 func (f File) Stat() (os.FileInfo, error) {
 	return f.Stats, nil
 }
@@ -129,21 +244,26 @@ func (f File) Stat() (os.FileInfo, error) {
 func (f *File) Truncate(size int64) error {
 	f.Lock()
 	defer f.Unlock()
-	if size > int64(len(*f.s)) {
-		f.Grow(size)
-		return nil
+	if err := f.canWrite(); err != nil {
+		return err
+	}
+	if size < 0 {
+		return ErrNeg
+	}
+	f.Stats.modified(f.user)
+	if size > f.s.size {
+		return f.Grow(size)
 	}
-	new := make([]byte, size)
-	n := copy(new, (*f.s)[:size])
-	if int64(n) != size {
-		panic(ErrBadCopy)
+	f.s.truncate(size)
+	if f.quota != nil {
+		f.quota.charge(f.Stats, size, f.s.held)
 	}
-	*f.s = new
 	return nil
 }
 
-//Dup creates a new File pointer
-//The new File pointer retains everything except seek position
+//Dup creates a copy of the handle.
+//The new handle starts at the same seek position and mode,
+//but moves independently from then on.
 func (f *File) Dup() *File {
 	new := *f
 	return &new
@@ -2,10 +2,13 @@ package fsutil
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"os"
 	"sync"
 	"testing"
+
+	"github.com/majiru/ffs"
 )
 
 func eRead(t *testing.T, f *File, b []byte, expect []byte) {
@@ -211,12 +214,48 @@ func TestClose(t *testing.T) {
 	if err != nil {
 		t.Fatal("Error returned err:", err)
 	}
-	if f.SeekPos() != 0 {
-		t.Fatalf("expected %d got %d for SeekPos after close", 0, f.SeekPos())
+	if _, err = f.Read(make([]byte, 1)); err != os.ErrClosed {
+		t.Fatalf("expected %v got %v for Read after close", os.ErrClosed, err)
 	}
 	if f2.SeekPos() != 3 {
 		t.Fatalf("expected %d got %d for SeekPos after dup", 3, f.SeekPos())
 	}
+	eRead(t, f2, make([]byte, 3), testStr[3:6])
+}
+
+func TestOpen(t *testing.T) {
+	testStr := []byte("Testing")
+	f := CreateFile([]byte(testStr), 0644, "test")
+	eRead(t, f, make([]byte, 3), testStr[:3])
+	r, err := f.Open(os.O_RDONLY)
+	if err != nil {
+		t.Fatal("Open returned err:", err)
+	}
+	if r.SeekPos() != 0 {
+		t.Fatal("SeekPos is not 0 for new handle")
+	}
+	eRead(t, r, make([]byte, 3), testStr[:3])
+	if _, err = r.Write(testStr); err != ErrReadOnly {
+		t.Fatalf("expected %v got %v for Write to read only handle", ErrReadOnly, err)
+	}
+	if err = r.Truncate(0); err != ErrReadOnly {
+		t.Fatalf("expected %v got %v for Truncate of read only handle", ErrReadOnly, err)
+	}
+	w, err := f.Open(os.O_WRONLY | os.O_APPEND)
+	if err != nil {
+		t.Fatal("Open returned err:", err)
+	}
+	if _, err = w.Read(make([]byte, 1)); err != ErrWriteOnly {
+		t.Fatalf("expected %v got %v for Read of write only handle", ErrWriteOnly, err)
+	}
+	eWriteAt(t, w, []byte("!"), 0)
+	eReadAt(t, r, make([]byte, 8), 0, []byte("Testing!"))
+	if _, err = f.Open(os.O_RDWR | os.O_TRUNC); err != nil {
+		t.Fatal("Open returned err:", err)
+	}
+	if f.Size() != 0 || r.Size() != 0 {
+		t.Fatal("O_TRUNC did not truncate the file")
+	}
 }
 
 func TestStats(t *testing.T) {
@@ -296,3 +335,51 @@ func TestReadWriteEof(t *testing.T) {
 
 	ioErrAt(io.EOF, 0, b, int64(len(testStr)+1), f.ReadAt)
 }
+
+func TestOwner(t *testing.T) {
+	f := CreateFile([]byte("Testing"), 0644, "test")
+	ctime := f.Stats.ChangeTime()
+	if f.Stats.Uid() != "" || f.Stats.Muid() != "" {
+		t.Fatal("new file has an owner")
+	}
+	f.Stats.Chown("glenda", "sys")
+	f.Stats.Chmod(0444)
+	if f.Stats.Uid() != "glenda" || f.Stats.Gid() != "sys" || f.Stats.Muid() != "glenda" {
+		t.Fatalf("expected glenda sys glenda got %s %s %s", f.Stats.Uid(), f.Stats.Gid(), f.Stats.Muid())
+	}
+	if f.Stats.Mode() != 0444 {
+		t.Fatalf("expected %v got %v for Mode()", os.FileMode(0444), f.Stats.Mode())
+	}
+	if !f.Stats.ChangeTime().After(ctime) {
+		t.Fatal("Chown did not update ChangeTime")
+	}
+	f.Stats.SetMuid("bootes")
+	if f.Stats.Muid() != "bootes" {
+		t.Fatalf("expected %s got %s for Muid", "bootes", f.Stats.Muid())
+	}
+	atime := f.Stats.AccessTime()
+	eRead(t, f, make([]byte, 4), []byte("Test"))
+	if !f.Stats.AccessTime().After(atime) {
+		t.Fatal("Read did not update AccessTime")
+	}
+}
+
+func TestMuid(t *testing.T) {
+	f := CreateFile([]byte("Testing"), 0644, "test")
+	f.Stats.Chown("glenda", "sys")
+	ctx := context.WithValue(context.Background(), ffs.UserKey{}, "bootes")
+	ro, _ := f.Open(os.O_RDONLY)
+	ro.SetContext(ctx)
+	if _, err := ro.Write([]byte("x")); err != ErrReadOnly {
+		t.Fatalf("expected %v got %v for write to read only handle", ErrReadOnly, err)
+	}
+	if f.Stats.Muid() != "glenda" {
+		t.Fatalf("failed write set Muid to %s", f.Stats.Muid())
+	}
+	rw, _ := f.Open(os.O_RDWR)
+	rw.SetContext(ctx)
+	eWrite(t, rw, []byte("x"))
+	if f.Stats.Muid() != "bootes" {
+		t.Fatalf("expected %s got %s for Muid", "bootes", f.Stats.Muid())
+	}
+}
@@ -0,0 +1,134 @@
+package fsutil
+
+//pageSize is the largest chunk of content held in one allocation.
+const pageSize = 4096
+
+//pages stores file content as a sparse set of fixed size pages.
+//Pages that were never written to are absent and read back as zeros,
+//a page is only as long as its furthest written byte.
+type pages struct {
+	m    map[int64][]byte
+	size int64
+	held int64
+}
+
+func newPages(content []byte) *pages {
+	p := &pages{m: make(map[int64][]byte)}
+	p.writeAt(content, 0)
+	return p
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+//need returns how many more bytes would be held
+//after a write of n bytes at off.
+func (p *pages) need(off, n int64) (extra int64) {
+	for n > 0 {
+		pg, po := off/pageSize, off%pageSize
+		chunk := min64(pageSize-po, n)
+		if end, have := po+chunk, int64(len(p.m[pg])); end > have {
+			extra += end - have
+		}
+		off += chunk
+		n -= chunk
+	}
+	return
+}
+
+func (p *pages) writeAt(b []byte, off int64) {
+	for len(b) > 0 {
+		pg, po := off/pageSize, off%pageSize
+		buf := p.m[pg]
+		end := po + min64(pageSize-po, int64(len(b)))
+		if have := int64(len(buf)); end > have {
+			if end <= int64(cap(buf)) {
+				buf = buf[:end]
+				//Truncate leaves old content past len
+				for i := have; i < po; i++ {
+					buf[i] = 0
+				}
+			} else {
+				c := 2 * int64(cap(buf))
+				if c < end {
+					c = end
+				}
+				if c > pageSize {
+					c = pageSize
+				}
+				new := make([]byte, end, c)
+				copy(new, buf)
+				buf = new
+			}
+			p.m[pg] = buf
+			p.held += end - have
+		}
+		n := copy(buf[po:end], b)
+		b = b[n:]
+		off += int64(n)
+	}
+	if off > p.size {
+		p.size = off
+	}
+}
+
+func (p *pages) readAt(b []byte, off int64) (n int) {
+	if off >= p.size {
+		return 0
+	}
+	if int64(len(b)) > p.size-off {
+		b = b[:p.size-off]
+	}
+	for n < len(b) {
+		pg, po := off/pageSize, off%pageSize
+		chunk := int(min64(pageSize-po, int64(len(b)-n)))
+		var c int
+		if buf := p.m[pg]; po < int64(len(buf)) {
+			c = copy(b[n:n+chunk], buf[po:])
+		}
+		for i := n + c; i < n+chunk; i++ {
+			b[i] = 0
+		}
+		n += chunk
+		off += int64(chunk)
+	}
+	return
+}
+
+func (p *pages) truncate(size int64) {
+	if size >= p.size {
+		p.size = size
+		return
+	}
+	last := (size+pageSize-1)/pageSize - 1
+	oldlast := (p.size+pageSize-1)/pageSize - 1
+	drop := func(pg int64) {
+		if buf, ok := p.m[pg]; ok {
+			p.held -= int64(len(buf))
+			delete(p.m, pg)
+		}
+	}
+	//Walk whichever is smaller, the dropped range or the held pages
+	if oldlast-last < int64(len(p.m)) {
+		for pg := last + 1; pg <= oldlast; pg++ {
+			drop(pg)
+		}
+	} else {
+		for pg := range p.m {
+			if pg > last {
+				drop(pg)
+			}
+		}
+	}
+	if keep := size - last*pageSize; last >= 0 {
+		if buf := p.m[last]; int64(len(buf)) > keep {
+			p.held -= int64(len(buf)) - keep
+			p.m[last] = buf[:keep]
+		}
+	}
+	p.size = size
+}
@@ -0,0 +1,121 @@
+package fsutil
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestSparse(t *testing.T) {
+	f := CreateFile([]byte{}, 0644, "test")
+	off := int64(1 << 40)
+	eWriteAt(t, f, []byte("Testing"), off)
+	if f.Size() != off+7 {
+		t.Fatalf("expected %d got %d for Size", off+7, f.Size())
+	}
+	if f.s.held > pageSize {
+		t.Fatalf("sparse write held %d bytes", f.s.held)
+	}
+	eReadAt(t, f, make([]byte, 7), off, []byte("Testing"))
+	b := make([]byte, 3*pageSize)
+	for i := range b {
+		b[i] = 'x'
+	}
+	eReadAt(t, f, b, off-int64(len(b)), nil)
+	if !bytes.Equal(b, make([]byte, len(b))) {
+		t.Fatal("hole did not read back as zeros")
+	}
+}
+
+func TestPageBoundary(t *testing.T) {
+	content := make([]byte, 3*pageSize+10)
+	for i := range content {
+		content[i] = byte(i)
+	}
+	f := CreateFile([]byte{}, 0644, "test")
+	//Write in chunks that do not line up with pages
+	for i := 0; i < len(content); i += 1000 {
+		end := i + 1000
+		if end > len(content) {
+			end = len(content)
+		}
+		eWrite(t, f, content[i:end])
+	}
+	b := make([]byte, len(content))
+	eReadAt(t, f, b, 0, content)
+	eReadAt(t, f, b[:pageSize], pageSize-5, content[pageSize-5:2*pageSize-5])
+}
+
+func TestTruncateZero(t *testing.T) {
+	testStr := []byte("Testing")
+	f := CreateFile(testStr, 0644, "test")
+	if err := f.Truncate(2); err != nil {
+		t.Fatal("Truncate returned error:", err)
+	}
+	eWriteAt(t, f, []byte("!"), 5)
+	eReadAt(t, f, make([]byte, 6), 0, []byte("Te\x00\x00\x00!"))
+	if err := f.Truncate(pageSize + 1); err != nil {
+		t.Fatal("Truncate returned error:", err)
+	}
+	eWriteAt(t, f, []byte("!"), pageSize)
+	if err := f.Truncate(1); err != nil {
+		t.Fatal("Truncate returned error:", err)
+	}
+	if len(f.s.m) != 1 || f.s.held != 1 {
+		t.Fatalf("expected 1 page holding 1 byte got %d pages holding %d", len(f.s.m), f.s.held)
+	}
+	if err := f.Truncate(0); err != nil {
+		t.Fatal("Truncate returned error:", err)
+	}
+	if len(f.s.m) != 0 || f.s.held != 0 || f.Size() != 0 {
+		t.Fatal("Truncate to 0 left content behind")
+	}
+}
+
+func TestQuotaSparse(t *testing.T) {
+	q := NewQuota(pageSize, 0)
+	f := CreateFile([]byte{}, 0644, "test")
+	if err := q.Track(f); err != nil {
+		t.Fatal("error tracking file:", err)
+	}
+	eWriteAt(t, f, []byte("Testing"), 1<<30)
+	if q.Used() > pageSize {
+		t.Fatalf("sparse write charged %d bytes", q.Used())
+	}
+}
+
+var benchSizes = []int{1 << 20, 16 << 20, 64 << 20}
+
+//The per byte cost of building a file should not depend on its size
+func BenchmarkAppend(b *testing.B) {
+	chunk := make([]byte, 512)
+	for _, size := range benchSizes {
+		b.Run(fmt.Sprintf("%dMB", size>>20), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				f := CreateFile([]byte{}, 0644, "bench")
+				for n := 0; n < size; n += len(chunk) {
+					f.Write(chunk)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkWriteAtRandom(b *testing.B) {
+	chunk := make([]byte, 512)
+	for _, size := range benchSizes {
+		b.Run(fmt.Sprintf("%dMB", size>>20), func(b *testing.B) {
+			r := rand.New(rand.NewSource(1))
+			span := int64(size) * 16
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				f := CreateFile([]byte{}, 0644, "bench")
+				for n := 0; n < size; n += len(chunk) {
+					f.WriteAt(chunk, r.Int63n(span))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package fsutil
+
+import (
+	"container/list"
+	"os"
+	"sync"
+
+	"github.com/majiru/ffs"
+)
+
+//Quota accounts for the memory used by a set of Files.
+//Limit bounds the memory held by all tracked files and FileLimit
+//bounds the size of any one of them, a value of zero means no limit.
+//As files are sparse, a file may be larger than the memory it holds.
+//
+//If Evict is set, writes that would exceed Limit instead drop the
+//least recently used files until the write fits. OnEvict is called
+//for every dropped file so the owner can unlink it from its tree.
+//As it runs while the writer holds the file, owners whose trees are
+//read concurrently should leave it unset and collect Evicted instead.
+type Quota struct {
+	sync.Mutex
+	Limit     int64
+	FileLimit int64
+	Evict     bool
+	OnEvict   func(fi os.FileInfo)
+	used      int64
+	lru       *list.List
+	files     map[*Stat]*list.Element
+	evicted   []os.FileInfo
+}
+
+type usage struct {
+	stat *Stat
+	held int64
+}
+
+//NewQuota creates a new Quota with the given limits.
+func NewQuota(limit, filelimit int64) *Quota {
+	return &Quota{
+		Limit:     limit,
+		FileLimit: filelimit,
+		lru:       list.New(),
+		files:     make(map[*Stat]*list.Element),
+	}
+}
+
+//Used returns the number of bytes of memory currently charged to q.
+func (q *Quota) Used() int64 {
+	q.Lock()
+	defer q.Unlock()
+	return q.used
+}
+
+//Track charges f, and every handle of it, against q.
+func (q *Quota) Track(f *File) error {
+	f.Lock()
+	defer f.Unlock()
+	if err := q.charge(f.Stats, f.s.size, f.s.held); err != nil {
+		return err
+	}
+	f.quota = q
+	return nil
+}
+
+//Release returns the space used by fi to q.
+func (q *Quota) Release(fi os.FileInfo) {
+	s, ok := fi.(*Stat)
+	if !ok {
+		return
+	}
+	q.Lock()
+	if e, ok := q.files[s]; ok {
+		q.used -= e.Value.(*usage).held
+		q.lru.Remove(e)
+		delete(q.files, s)
+	}
+	q.Unlock()
+}
+
+//Evicted returns the files dropped since it was last called,
+//if OnEvict is not set.
+func (q *Quota) Evicted() []os.FileInfo {
+	q.Lock()
+	defer q.Unlock()
+	out := q.evicted
+	q.evicted = nil
+	return out
+}
+
+func (q *Quota) touch(s *Stat) {
+	if q == nil {
+		return
+	}
+	q.Lock()
+	if e, ok := q.files[s]; ok {
+		q.lru.MoveToFront(e)
+	}
+	q.Unlock()
+}
+
+//charge records that the file described by s is now size bytes long
+//and holds held bytes of memory.
+//A file that has been evicted is charged again in full.
+func (q *Quota) charge(s *Stat, size, held int64) error {
+	if q.FileLimit > 0 && size > q.FileLimit {
+		return ffs.ErrNoSpace
+	}
+	q.Lock()
+	e, ok := q.files[s]
+	if !ok {
+		e = q.lru.PushFront(&usage{s, 0})
+		q.files[s] = e
+	}
+	u := e.Value.(*usage)
+	delta := held - u.held
+	var victims []*Stat
+	if q.Limit > 0 && delta > 0 && q.used+delta > q.Limit {
+		if victims = q.victims(s, q.used+delta-q.Limit); victims == nil {
+			if !ok {
+				q.lru.Remove(e)
+				delete(q.files, s)
+			}
+			q.Unlock()
+			return ffs.ErrNoSpace
+		}
+	}
+	q.used += delta
+	u.held = held
+	q.lru.MoveToFront(e)
+	if q.OnEvict == nil {
+		for _, v := range victims {
+			q.evicted = append(q.evicted, v)
+		}
+	}
+	q.Unlock()
+
+	//OnEvict may walk trees that call back into q,
+	//so it is run after we let go of the lock.
+	for _, v := range victims {
+		if q.OnEvict != nil {
+			q.OnEvict(v)
+		}
+	}
+	return nil
+}
+
+//victims releases the least recently used files, excluding keep,
+//until at least need bytes have been freed.
+//Nothing is released if that is not possible.
+func (q *Quota) victims(keep *Stat, need int64) (out []*Stat) {
+	if !q.Evict {
+		return nil
+	}
+	var freed int64
+	for e := q.lru.Back(); e != nil && freed < need; e = e.Prev() {
+		u := e.Value.(*usage)
+		if u.stat == keep {
+			continue
+		}
+		out = append(out, u.stat)
+		freed += u.held
+	}
+	if freed < need {
+		return nil
+	}
+	for _, s := range out {
+		e := q.files[s]
+		q.used -= e.Value.(*usage).held
+		q.lru.Remove(e)
+		delete(q.files, s)
+	}
+	return
+}
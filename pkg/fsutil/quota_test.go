@@ -0,0 +1,89 @@
+package fsutil
+
+import (
+	"os"
+	"testing"
+
+	"github.com/majiru/ffs"
+)
+
+func TestQuotaLimit(t *testing.T) {
+	q := NewQuota(10, 0)
+	f := CreateFile([]byte{}, 0644, "test")
+	if err := q.Track(f); err != nil {
+		t.Fatal("error tracking file:", err)
+	}
+	eWrite(t, f, []byte("Testing"))
+	if q.Used() != 7 {
+		t.Fatalf("expected %d got %d for Used", 7, q.Used())
+	}
+	if _, err := f.Write([]byte("Testing")); err != ffs.ErrNoSpace {
+		t.Fatalf("expected %v got %v for write past limit", ffs.ErrNoSpace, err)
+	}
+	if f.Size() != 7 {
+		t.Fatalf("failed write changed size to %d", f.Size())
+	}
+	if err := f.Truncate(2); err != nil {
+		t.Fatal("Truncate returned error:", err)
+	}
+	if q.Used() != 2 {
+		t.Fatalf("expected %d got %d for Used after Truncate", 2, q.Used())
+	}
+	if _, err := f.WriteAt([]byte("Testing"), 3); err != nil {
+		t.Fatal("write after Truncate:", err)
+	}
+	q.Release(f.Stats)
+	if q.Used() != 0 {
+		t.Fatalf("expected %d got %d for Used after Release", 0, q.Used())
+	}
+}
+
+func TestQuotaFileLimit(t *testing.T) {
+	q := NewQuota(0, 4)
+	f := CreateFile([]byte("Test"), 0644, "test")
+	if err := q.Track(f); err != nil {
+		t.Fatal("error tracking file:", err)
+	}
+	if err := f.Truncate(5); err != ffs.ErrNoSpace {
+		t.Fatalf("expected %v got %v for Truncate past limit", ffs.ErrNoSpace, err)
+	}
+	big := CreateFile([]byte("Testing"), 0644, "big")
+	if err := q.Track(big); err != ffs.ErrNoSpace {
+		t.Fatalf("expected %v got %v for tracking oversized file", ffs.ErrNoSpace, err)
+	}
+	if q.Used() != 4 {
+		t.Fatalf("expected %d got %d for Used", 4, q.Used())
+	}
+}
+
+func TestQuotaEvict(t *testing.T) {
+	var evicted []string
+	q := NewQuota(10, 0)
+	q.Evict = true
+	q.OnEvict = func(fi os.FileInfo) {
+		evicted = append(evicted, fi.Name())
+	}
+	files := make([]*File, 3)
+	for i, n := range []string{"a", "b", "c"} {
+		files[i] = CreateFile([]byte{}, 0644, n)
+		if err := q.Track(files[i]); err != nil {
+			t.Fatal("error tracking file:", err)
+		}
+		eWrite(t, files[i], []byte("123"))
+	}
+	//Reading a marks it as recently used, so b should go first.
+	files[0].ReadAt(make([]byte, 1), 0)
+	eWrite(t, files[2], []byte("45"))
+	if len(evicted) != 1 || evicted[0] != "b" {
+		t.Fatalf("expected [b] got %v for evicted files", evicted)
+	}
+	if q.Used() != 8 {
+		t.Fatalf("expected %d got %d for Used after eviction", 8, q.Used())
+	}
+	if _, err := files[2].WriteAt(make([]byte, 11), 0); err != ffs.ErrNoSpace {
+		t.Fatalf("expected %v got %v for write larger than limit", ffs.ErrNoSpace, err)
+	}
+	if len(evicted) != 1 {
+		t.Fatalf("failed write evicted %v", evicted)
+	}
+}
@@ -2,30 +2,126 @@ package fsutil
 
 import (
 	"os"
+	"sync"
 	"time"
 )
 
 //Stat implements os.FileInfo
+//Uid, Gid and Muid satisfy styx.OwnerInfo, so ownership
+//is reported to 9p clients. A Stat with no owner is
+//treated as being owned by everyone.
+//The mode, times and owners may change while the file is in use,
+//so they are read and written under mu.
 type Stat struct {
-	perm os.FileMode
-	name string
-	time time.Time
-	size int64
-	File interface{}
+	mu    sync.RWMutex
+	perm  os.FileMode
+	name  string
+	time  time.Time
+	size  int64
+	File  interface{}
+	uid   string
+	gid   string
+	muid  string
+	ctime time.Time
 }
 
-func (s Stat) Name() string     { return s.name }
-func (s Stat) Sys() interface{} { return s.File }
+func newStat(perm os.FileMode, name string, size int64, sys interface{}) *Stat {
+	now := time.Now()
+	return &Stat{perm: perm, name: name, time: now, size: size, File: sys, ctime: now}
+}
+
+func (s *Stat) Name() string     { return s.name }
+func (s *Stat) Sys() interface{} { return s.File }
 
-func (s Stat) ModTime() time.Time { return s.time }
+func (s *Stat) ModTime() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.time
+}
 
-func (s Stat) Mode() os.FileMode { return s.perm }
+func (s *Stat) Mode() os.FileMode {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.perm
+}
 
-func (s Stat) IsDir() bool { return s.perm.IsDir() }
+func (s *Stat) IsDir() bool { return s.Mode().IsDir() }
 
-func (s Stat) Size() int64 {
+func (s *Stat) Size() int64 {
 	if f, ok := s.File.(interface{ Size() int64 }); ok {
 		return f.Size()
 	}
 	return s.size
 }
+
+func (s *Stat) Uid() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.uid
+}
+
+func (s *Stat) Gid() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.gid
+}
+
+//Muid returns the last user to modify the file,
+//falling back to the owner.
+func (s *Stat) Muid() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.muid == "" {
+		return s.uid
+	}
+	return s.muid
+}
+
+//AccessTime returns the last time the file was read.
+func (s *Stat) AccessTime() time.Time {
+	if f, ok := s.File.(interface{ AccessTime() time.Time }); ok {
+		return f.AccessTime()
+	}
+	return s.ModTime()
+}
+
+//ChangeTime returns the last time the ownership or mode of the file changed.
+func (s *Stat) ChangeTime() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ctime
+}
+
+//Chown sets the owner and group of the file.
+func (s *Stat) Chown(uid, gid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.uid, s.gid = uid, gid
+	s.ctime = time.Now()
+}
+
+//Chmod sets the permission bits of the file, the type bits are kept.
+func (s *Stat) Chmod(perm os.FileMode) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.perm = s.perm&^os.ModePerm | perm&os.ModePerm
+	s.ctime = time.Now()
+}
+
+//SetMuid records user as the last to modify the file.
+func (s *Stat) SetMuid(user string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.muid = user
+}
+
+//modified records a change to the content by user,
+//who may be empty if not known.
+func (s *Stat) modified(user string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.time = time.Now()
+	if user != "" {
+		s.muid = user
+	}
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"log"
 	"os"
 
@@ -8,7 +9,17 @@ import (
 	"github.com/majiru/ffs"
 )
 
+//truncater is implemented by files that can be truncated
+//without being opened, such as fsutil.File and synthfile.File.
+type truncater interface {
+	Truncate(size int64) error
+}
+
 func (srv Server) Serve9P(s *styx.Session) {
+	//Files opened in the session are cancelled when it ends
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = context.WithValue(ctx, ffs.UserKey{}, s.User)
 	for s.Next() {
 		msg := s.Request()
 		fi, err := srv.Fs.Stat(msg.Path())
@@ -21,16 +32,30 @@ func (srv Server) Serve9P(s *styx.Session) {
 		case styx.Twalk:
 			t.Rwalk(fi, nil)
 		case styx.Topen:
-			if fi.IsDir() {
+			if err = access(fi, s.User, t.Flag); err != nil {
+				t.Rerror(err.Error())
+			} else if fi.IsDir() {
 				t.Ropen(srv.Fs.ReadDir(msg.Path()))
 			} else {
-				t.Ropen(srv.Fs.Open(msg.Path(), t.Flag))
+				f, err := srv.Fs.Open(msg.Path(), t.Flag)
+				if err == nil {
+					setContext(f, ctx)
+				}
+				t.Ropen(f, err)
 			}
 		case styx.Tstat:
 			t.Rstat(fi, nil)
 		case styx.Ttruncate:
-			if w, ok := fi.Sys().(ffs.Writer); ok {
-				t.Rtruncate(w.Truncate(t.Size))
+			if err = access(fi, s.User, os.O_WRONLY); err != nil {
+				t.Rerror(err.Error())
+			} else if w, ok := fi.Sys().(truncater); ok {
+				err = w.Truncate(t.Size)
+				if m, ok := fi.(interface{ SetMuid(string) }); ok && err == nil {
+					m.SetMuid(s.User)
+				}
+				t.Rtruncate(err)
+			} else {
+				t.Rerror(os.ErrPermission.Error())
 			}
 		}
 	}
@@ -1,7 +1,9 @@
 package server
 
 import (
+	"context"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
@@ -11,6 +13,58 @@ import (
 	"github.com/majiru/ffs"
 )
 
+//httpError reports err to the client,
+//mapping errors from the fs to the matching status code.
+func httpError(w http.ResponseWriter, r *http.Request, err error) {
+	switch err {
+	case ffs.ErrNoSpace:
+		http.Error(w, "Request entity too large", http.StatusRequestEntityTooLarge)
+	case os.ErrPermission:
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	default:
+		log.Println("Error: " + err.Error() + " for request " + r.URL.Path)
+		http.Error(w, "Internal server error", 500)
+	}
+}
+
+//spooled is a temporary file, closing it removes it.
+type spooled struct {
+	*os.File
+}
+
+func (s spooled) Close() error {
+	s.File.Close()
+	return os.Remove(s.Name())
+}
+
+//spool copies what is left of r to a temporary file,
+//so large files are not held in memory.
+func spool(r io.Reader) (spooled, error) {
+	f, err := ioutil.TempFile("", "ffs")
+	if err != nil {
+		return spooled{}, err
+	}
+	s := spooled{f}
+	if _, err = io.Copy(f, r); err != nil {
+		s.Close()
+		return spooled{}, err
+	}
+	return s, nil
+}
+
+//requestContext is the context of r with the base URL and user of the request.
+func requestContext(r *http.Request) context.Context {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	ctx := context.WithValue(r.Context(), ffs.BaseURLKey{}, scheme+"://"+r.Host)
+	if user := httpUser(r); user != "" {
+		ctx = context.WithValue(ctx, ffs.UserKey{}, user)
+	}
+	return ctx
+}
+
 func (srv Server) ReadHTTP(w http.ResponseWriter, r *http.Request, path string) (file ffs.File, err error) {
 	file, err = srv.Fs.Open(path, os.O_RDONLY)
 	if err != nil {
@@ -19,15 +73,17 @@ func (srv Server) ReadHTTP(w http.ResponseWriter, r *http.Request, path string)
 			http.NotFoundHandler().ServeHTTP(w, r)
 			return
 		}
-		log.Println("Error: " + err.Error() + " for request " + r.URL.Path)
-		http.Error(w, "Internal server error", 500)
+		httpError(w, r, err)
 		return
 	}
+	setContext(file, requestContext(r))
 	return
 }
 
 func (srv Server) WriteHTTP(w http.ResponseWriter, r *http.Request, path string) (content ffs.Writer, err error) {
-	file, err := srv.Fs.Open(path, os.O_RDWR|os.O_TRUNC)
+	//The file is not opened with O_TRUNC, as PUT and POST
+	//decide for themselves how much of the old content is kept.
+	file, err := srv.Fs.Open(path, os.O_RDWR)
 	content, ok := file.(ffs.Writer)
 	if !ok || err != nil {
 		if err == os.ErrNotExist {
@@ -35,25 +91,30 @@ func (srv Server) WriteHTTP(w http.ResponseWriter, r *http.Request, path string)
 			http.NotFoundHandler().ServeHTTP(w, r)
 			return
 		}
-		log.Println("Error: " + err.Error() + " for request " + r.URL.Path)
-		http.Error(w, "Internal server error", 500)
+		httpError(w, r, err)
 		return
 	}
+	setContext(content, requestContext(r))
 	//As a special case, POST requests that upload
 	//a file, instead write the first uploaded file
 	//BUG: This drops other form information.
 	if mr, err := r.MultipartReader(); err == nil {
+		var written int64
 		for {
 			p, err := mr.NextPart()
 			if err == io.EOF {
+				content.Truncate(written)
 				return nil, nil
 			}
 			if err != nil {
-				log.Fatal(err)
+				httpError(w, r, err)
+				return nil, err
 			}
-			_, err = io.Copy(content, p)
+			n, err := io.Copy(content, p)
+			written += n
 			if err != nil {
-				log.Fatal(err)
+				httpError(w, r, err)
+				return nil, err
 			}
 		}
 	}
@@ -71,10 +132,19 @@ func (srv Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.NotFoundHandler().ServeHTTP(w, r)
 		return
 	}
+	flag := os.O_RDONLY
+	if r.Method == http.MethodPost || r.Method == http.MethodPut {
+		flag = os.O_RDWR
+	}
+	if err = access(fi, httpUser(r), flag); err != nil {
+		httpError(w, r, err)
+		return
+	}
 	switch r.Method {
 	case http.MethodGet:
 		content, err := srv.ReadHTTP(w, r, requestedFile)
 		if err == nil && content != nil {
+			setContentType(w, content)
 			http.ServeContent(w, r, requestedFile, fi.ModTime(), content)
 			content.Close()
 		}
@@ -84,27 +154,56 @@ func (srv Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		content, err := srv.WriteHTTP(w, r, requestedFile)
 		if err == nil && content != nil {
 			content.Seek(0, io.SeekStart)
-			io.Copy(content, r.Body)
+			n, err := io.Copy(content, r.Body)
+			if err != nil {
+				content.Close()
+				httpError(w, r, err)
+				return
+			}
+			//An empty body only asks for the content
+			if n > 0 {
+				content.Truncate(n)
+			}
 			content.Seek(0, io.SeekStart)
+			http.ServeContent(w, r, requestedFile, fi.ModTime(), content)
 			if err = content.Close(); err != nil {
-				log.Fatal(err)
+				log.Println("Error: " + err.Error() + " closing " + r.URL.Path)
 			}
-			http.ServeContent(w, r, requestedFile, fi.ModTime(), content)
 		}
 	//Put expects the input to be reflected in the desired file.
-	//In this case the contents of the file are sent to the client before
-	//being overwritten.
+	//In this case the previous contents of the file are sent to the client.
+	//They are spooled to disk until the write is done, so that a failed
+	//write can be reported instead and the file restored.
 	case http.MethodPut:
 		content, err := srv.WriteHTTP(w, r, requestedFile)
 		if err == nil && content != nil {
-			http.ServeContent(w, r, requestedFile, fi.ModTime(), content)
 			content.Seek(0, io.SeekStart)
-			n, _ := io.Copy(content, r.Body)
+			old, err := spool(content)
+			if err != nil {
+				content.Close()
+				httpError(w, r, err)
+				return
+			}
+			defer old.Close()
+			content.Seek(0, io.SeekStart)
+			n, err := io.Copy(content, r.Body)
+			if err != nil {
+				old.Seek(0, io.SeekStart)
+				content.Seek(0, io.SeekStart)
+				m, _ := io.Copy(content, old)
+				content.Truncate(m)
+				content.Close()
+				httpError(w, r, err)
+				return
+			}
 			content.Truncate(n)
 			content.Seek(0, io.SeekStart)
 			if err = content.Close(); err != nil {
-				log.Fatal(err)
+				httpError(w, r, err)
+				return
 			}
+			old.Seek(0, io.SeekStart)
+			http.ServeContent(w, r, requestedFile, fi.ModTime(), old)
 		}
 	}
 	return
@@ -2,6 +2,7 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"io/ioutil"
 	"mime/multipart"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/majiru/aitm"
 	"github.com/majiru/ffs/fs/ramfs"
 	"github.com/majiru/ffs/pkg/fsutil"
 )
@@ -136,6 +138,26 @@ func TestPOST(t *testing.T) {
 	}
 }
 
+//POST replaces the file, a shorter body leaves nothing of the old one.
+func TestPostShorter(t *testing.T) {
+	srv := testServer()
+	srv.Start()
+	c := srv.Client()
+	resp, err := c.Post(srv.URL+"/index.html", "text/plain", strings.NewReader("ab"))
+	if err != nil {
+		t.Fatal("Error performing post:", err)
+	}
+	resp.Body.Close()
+	resp, err = c.Get(srv.URL + "/index.html")
+	if err != nil {
+		t.Fatal("Error performing get:", err)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	if string(b) != "ab" {
+		t.Fatalf("content mismatch: saw %q, expected %q", b, "ab")
+	}
+}
+
 func TestPostEmpty(t *testing.T) {
 	srv := testServer()
 	srv.Start()
@@ -245,3 +267,99 @@ func TestMultipart(t *testing.T) {
 		t.Fatal("content mismatch")
 	}
 }
+
+func TestPutCloseError(t *testing.T) {
+	srv := httptest.NewServer(Server{Fs: &CloseErrFs{}})
+	defer srv.Close()
+	c := srv.Client()
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest("PUT", srv.URL+"/index.html", strings.NewReader(m2))
+		if err != nil {
+			t.Fatal("could not create req:", err)
+		}
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatal("could not perfrom http request:", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusInternalServerError {
+			t.Fatal("expected 500 from resp, got:", resp.StatusCode)
+		}
+	}
+}
+
+func TestQuota(t *testing.T) {
+	fs := &ramfs.Ramfs{Root: fsutil.CreateDir("/"), Quota: fsutil.NewQuota(0, int64(len(m1)))}
+	f := fsutil.CreateFile([]byte(m1), 0644, "index.html")
+	if err := fs.Quota.Track(f); err != nil {
+		t.Fatal("error tracking file:", err)
+	}
+	fs.Root.Append(f.Stats)
+	srv := httptest.NewServer(Server{fs})
+	defer srv.Close()
+	c := srv.Client()
+	req, err := http.NewRequest("PUT", srv.URL+"/index.html", strings.NewReader(m1+m2))
+	if err != nil {
+		t.Fatal("could not create req:", err)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal("could not perfrom http request:", err)
+	}
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatal("expected 413 from resp, got:", resp.StatusCode)
+	}
+	resp, err = c.Get(srv.URL + "/index.html")
+	if err != nil {
+		t.Fatal("could not perform get:", err)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal("could not read resp:", err)
+	}
+	if string(b) != m1 {
+		t.Fatal("content not restored after failed write")
+	}
+}
+
+func TestPermission(t *testing.T) {
+	fs := &ramfs.Ramfs{Root: fsutil.CreateDir("/")}
+	ro := fsutil.CreateFile([]byte(m1), 0444, "ro.html")
+	owned := fsutil.CreateFile([]byte(m1), 0644, "owned.html")
+	owned.Stats.Chown("glenda", "glenda")
+	fs.Root.Append(ro.Stats, owned.Stats)
+	//Stand in for aitm, which sets the user on login
+	var user string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user != "" {
+			r = r.WithContext(context.WithValue(r.Context(), aitm.TokenContextKey{}, &aitm.Token{Username: user}))
+		}
+		Server{fs}.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	c := srv.Client()
+	do := func(method, path string, expect int) {
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(m2))
+		if err != nil {
+			t.Fatal("could not create req:", err)
+		}
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatal("could not perform http request:", err)
+		}
+		if resp.StatusCode != expect {
+			t.Fatalf("expected %d got %d for %s %s as %q", expect, resp.StatusCode, method, path, user)
+		}
+	}
+	do("GET", "/ro.html", http.StatusOK)
+	do("PUT", "/ro.html", http.StatusForbidden)
+	do("POST", "/ro.html", http.StatusForbidden)
+	do("GET", "/owned.html", http.StatusOK)
+	do("PUT", "/owned.html", http.StatusForbidden)
+	user = "glenda"
+	do("PUT", "/ro.html", http.StatusForbidden)
+	do("PUT", "/owned.html", http.StatusOK)
+	if owned.Stats.Muid() != "glenda" {
+		t.Fatalf("expected %s got %s for Muid", "glenda", owned.Stats.Muid())
+	}
+}
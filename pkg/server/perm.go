@@ -0,0 +1,59 @@
+package server
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/majiru/aitm"
+)
+
+//owner is satisfied by file info that knows who owns the file,
+//it is a subset of styx.OwnerInfo.
+type owner interface {
+	Uid() string
+	Gid() string
+}
+
+//access checks that user may open fi with flag.
+//Files that do not report an owner are checked against
+//the owner permission bits for every user.
+//There is no group database, a user is only
+//a member of the group of the same name.
+func access(fi os.FileInfo, user string, flag int) error {
+	perm := fi.Mode().Perm()
+	bits := perm >> 6
+	if o, ok := fi.(owner); ok && o.Uid() != "" {
+		switch {
+		case user == o.Uid():
+		case user != "" && user == o.Gid():
+			bits = perm >> 3
+		default:
+			bits = perm
+		}
+	}
+	var want os.FileMode
+	switch flag & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR) {
+	case os.O_RDONLY:
+		want = 04
+	case os.O_WRONLY:
+		want = 02
+	case os.O_RDWR:
+		want = 06
+	}
+	if flag&os.O_TRUNC != 0 {
+		want |= 02
+	}
+	if bits&want != want {
+		return os.ErrPermission
+	}
+	return nil
+}
+
+//httpUser returns the user authenticated by aitm,
+//or an empty string for anonymous requests.
+func httpUser(r *http.Request) string {
+	if t, ok := r.Context().Value(aitm.TokenContextKey{}).(*aitm.Token); ok {
+		return t.Username
+	}
+	return ""
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"os"
+	"testing"
+
+	"github.com/majiru/ffs/pkg/fsutil"
+)
+
+func TestAccess(t *testing.T) {
+	stat := func(perm os.FileMode, uid, gid string) os.FileInfo {
+		s := fsutil.CreateFile([]byte{}, perm, "test").Stats
+		s.Chown(uid, gid)
+		return s
+	}
+	tests := []struct {
+		fi   os.FileInfo
+		user string
+		flag int
+		err  error
+	}{
+		{stat(0644, "", ""), "", os.O_RDWR, nil},
+		{stat(0444, "", ""), "", os.O_RDONLY, nil},
+		{stat(0444, "", ""), "glenda", os.O_WRONLY, os.ErrPermission},
+		{stat(0444, "", ""), "", os.O_RDONLY | os.O_TRUNC, os.ErrPermission},
+		{stat(0600, "glenda", "sys"), "glenda", os.O_RDWR, nil},
+		{stat(0600, "glenda", "sys"), "", os.O_RDONLY, os.ErrPermission},
+		{stat(0640, "glenda", "sys"), "sys", os.O_RDONLY, nil},
+		{stat(0640, "glenda", "sys"), "sys", os.O_WRONLY, os.ErrPermission},
+		{stat(0604, "glenda", "sys"), "bootes", os.O_RDONLY, nil},
+		{stat(0640, "glenda", ""), "", os.O_RDONLY, os.ErrPermission},
+		{stat(0640, "glenda", ""), "bootes", os.O_RDONLY, os.ErrPermission},
+		{stat(0200, "glenda", "sys"), "glenda", os.O_RDONLY, os.ErrPermission},
+	}
+	for i, test := range tests {
+		if err := access(test.fi, test.user, test.flag); err != test.err {
+			t.Errorf("%d: expected %v got %v", i, test.err, err)
+		}
+	}
+}
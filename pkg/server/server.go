@@ -1,7 +1,39 @@
 package server
 
-import "github.com/majiru/ffs"
+import (
+	"context"
+	"net/http"
+
+	"github.com/majiru/ffs"
+)
 
 type Server struct {
 	Fs ffs.Fs
 }
+
+//contexter is implemented by files whose requests
+//can be cancelled, such as chanfile.File.
+type contexter interface {
+	SetContext(ctx context.Context)
+}
+
+//setContext ties f to the lifetime of the client's connection.
+func setContext(f interface{}, ctx context.Context) {
+	if c, ok := f.(contexter); ok {
+		c.SetContext(ctx)
+	}
+}
+
+//contentTyper is implemented by files that know their MIME type
+//better than their name tells.
+type contentTyper interface {
+	ContentType() string
+}
+
+//setContentType sets the Content-Type of the response to f's own,
+//otherwise http.ServeContent guesses it from the name and content.
+func setContentType(w http.ResponseWriter, f interface{}) {
+	if c, ok := f.(contentTyper); ok && c.ContentType() != "" {
+		w.Header().Set("Content-Type", c.ContentType())
+	}
+}
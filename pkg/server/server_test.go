@@ -40,3 +40,27 @@ func (fs *ErrFs) ReadDir(path string) (ffs.Dir, error) {
 func (fs *ErrFs) Stat(path string) (os.FileInfo, error) {
 	return fsutil.CreateFile([]byte{}, 0644, "test").Stats, nil
 }
+
+// CloseErrFs allows for tests on writes that fail when the file is closed
+type CloseErrFs struct{}
+
+type closeErrFile struct {
+	*fsutil.File
+}
+
+func (f closeErrFile) Close() error {
+	return ErrBogus
+}
+
+func (fs *CloseErrFs) Open(path string, mode int) (ffs.File, error) {
+	f, err := fsutil.CreateFile([]byte(m1), 0644, "test").Open(mode)
+	return closeErrFile{f}, err
+}
+
+func (fs *CloseErrFs) ReadDir(path string) (ffs.Dir, error) {
+	return nil, ErrBogus
+}
+
+func (fs *CloseErrFs) Stat(path string) (os.FileInfo, error) {
+	return fsutil.CreateFile([]byte{}, 0644, "test").Stats, nil
+}
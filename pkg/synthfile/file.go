@@ -0,0 +1,208 @@
+//Package synthfile implements synthetic files driven by callbacks.
+//
+//Unlike chanfile, no goroutine has to be run to serve the file.
+//Every open of a File creates its own Handle, so clients
+//never see each others messages or wait on one another.
+package synthfile
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"github.com/majiru/ffs/pkg/fsutil"
+)
+
+//Handler holds the callbacks of a synthetic file.
+//Each callback is given the Handle it was called on,
+//per open state can be kept in Handle.Aux.
+//A nil callback passes the operation through to Handle.Content.
+//
+//Calls on one Handle are serialized, calls on different Handles are not,
+//callbacks must guard any state they share between Handles.
+type Handler struct {
+	OnOpen     func(h *Handle) error
+	OnRead     func(h *Handle, b []byte, off int64) (int, error)
+	OnWrite    func(h *Handle, b []byte, off int64) (int, error)
+	OnTruncate func(h *Handle, size int64) error
+	OnClose    func(h *Handle) error
+}
+
+//File is a synthetic file, it is not read or written directly
+//but through the Handles returned by Open.
+type File struct {
+	Handler
+	Content *fsutil.File
+}
+
+//CreateFile creates a new File with the given callbacks.
+//The underlying Stats.Sys() points to the new File.
+func CreateFile(content []byte, mode os.FileMode, name string, h Handler) *File {
+	return WrapFile(fsutil.CreateFile(content, mode, name), h)
+}
+
+//WrapFile creates a new File with the given callbacks around f.
+func WrapFile(f *fsutil.File, h Handler) *File {
+	synth := &File{h, f}
+	synth.Content.Stats.File = synth
+	return synth
+}
+
+func (f *File) Stat() (os.FileInfo, error) {
+	return f.Content.Stats, nil
+}
+
+//Open creates a new Handle to f.
+//Handle.Content starts as a handle of f.Content opened with flag,
+//callbacks may replace it to give the Handle content of its own.
+func (f *File) Open(flag int) (*Handle, error) {
+	content, err := f.Content.Open(flag)
+	if err != nil {
+		return nil, err
+	}
+	h := &Handle{File: f, Content: content}
+	if f.OnOpen != nil {
+		if err = f.OnOpen(h); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+//Truncate truncates f through a Handle of its own, so OnTruncate
+//sees changes made without opening the file, as by a 9p wstat.
+func (f *File) Truncate(size int64) error {
+	h, err := f.Open(os.O_WRONLY)
+	if err != nil {
+		return err
+	}
+	err = h.Truncate(size)
+	if cerr := h.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+//Handle is an open instance of a File.
+type Handle struct {
+	mu      sync.Mutex
+	File    *File
+	Content *fsutil.File
+	Aux     interface{}
+	i       int64
+	closed  bool
+}
+
+func (h *Handle) readAt(b []byte, off int64) (int, error) {
+	if h.closed {
+		return 0, os.ErrClosed
+	}
+	if h.File.OnRead != nil {
+		return h.File.OnRead(h, b, off)
+	}
+	return h.Content.ReadAt(b, off)
+}
+
+func (h *Handle) writeAt(b []byte, off int64) (int, error) {
+	if h.closed {
+		return 0, os.ErrClosed
+	}
+	if h.File.OnWrite != nil {
+		return h.File.OnWrite(h, b, off)
+	}
+	return h.Content.WriteAt(b, off)
+}
+
+func (h *Handle) Read(b []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n, err := h.readAt(b, h.i)
+	h.i += int64(n)
+	//A short read is not an error for Read
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+func (h *Handle) ReadAt(b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fsutil.ErrNeg
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.readAt(b, off)
+}
+
+func (h *Handle) Write(b []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n, err := h.writeAt(b, h.i)
+	h.i += int64(n)
+	return n, err
+}
+
+func (h *Handle) WriteAt(b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fsutil.ErrNeg
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.writeAt(b, off)
+}
+
+func (h *Handle) Seek(offset int64, whence int) (int64, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return 0, os.ErrClosed
+	}
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = h.i + offset
+	case io.SeekEnd:
+		abs = h.Content.Size() + offset
+	default:
+		return 0, fsutil.ErrInvalWhence
+	}
+	if abs < 0 {
+		return 0, fsutil.ErrNeg
+	}
+	h.i = abs
+	return abs, nil
+}
+
+func (h *Handle) Truncate(size int64) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return os.ErrClosed
+	}
+	if h.File.OnTruncate != nil {
+		return h.File.OnTruncate(h, size)
+	}
+	return h.Content.Truncate(size)
+}
+
+//Close calls OnClose and releases the Handle,
+//the Handle is released even if OnClose returns an error.
+func (h *Handle) Close() (err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return os.ErrClosed
+	}
+	if h.File.OnClose != nil {
+		err = h.File.OnClose(h)
+	}
+	h.closed = true
+	h.Content.Close()
+	return
+}
+
+func (h *Handle) Stat() (os.FileInfo, error) {
+	return h.File.Stat()
+}
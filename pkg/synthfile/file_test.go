@@ -0,0 +1,173 @@
+package synthfile
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/majiru/ffs"
+	"github.com/majiru/ffs/pkg/fsutil"
+)
+
+var m1 = []byte("Hello World")
+
+func TestPassthrough(t *testing.T) {
+	f := CreateFile([]byte{}, 0644, "test", Handler{})
+	h, err := f.Open(os.O_RDWR)
+	if err != nil {
+		t.Fatal("error opening synthfile:", err)
+	}
+	if n, err := h.Write(m1); err != nil || n != len(m1) {
+		t.Fatalf("expected %d <nil> got %d %v from Write", len(m1), n, err)
+	}
+	h.Seek(0, io.SeekStart)
+	b, err := ioutil.ReadAll(h)
+	if err != nil {
+		t.Fatal("error reading synthfile:", err)
+	}
+	if string(b) != string(m1) {
+		t.Fatalf("expected %s got %s", m1, b)
+	}
+	if err = h.Truncate(5); err != nil {
+		t.Fatal("Truncate returned error:", err)
+	}
+	if f.Content.Size() != 5 {
+		t.Fatalf("expected %d got %d for Size", 5, f.Content.Size())
+	}
+	if err = h.Close(); err != nil {
+		t.Fatal("Close returned error:", err)
+	}
+	if _, err = h.Read(b); err != os.ErrClosed {
+		t.Fatalf("expected %v got %v", os.ErrClosed, err)
+	}
+	if fi, _ := h.Stat(); fi.Sys() != f {
+		t.Fatal("Stat does not point back to the File")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	var got int64 = -1
+	f := CreateFile(m1, 0644, "test", Handler{
+		OnTruncate: func(h *Handle, size int64) error {
+			got = size
+			return h.Content.Truncate(size)
+		},
+	})
+	if err := f.Truncate(5); err != nil {
+		t.Fatal("Truncate returned error:", err)
+	}
+	if got != 5 || f.Content.Size() != 5 {
+		t.Fatalf("expected %d got %d %d from OnTruncate and Size", 5, got, f.Content.Size())
+	}
+	ro := CreateFile(m1, 0444, "ro", Handler{
+		OnOpen: func(h *Handle) error { return os.ErrPermission },
+	})
+	if err := ro.Truncate(0); err != os.ErrPermission {
+		t.Fatalf("expected %v got %v", os.ErrPermission, err)
+	}
+}
+
+//echo replies to each write with the uppercase of what was written,
+//each handle only sees its own reply.
+func echo() Handler {
+	return Handler{
+		OnOpen: func(h *Handle) error {
+			h.Content = fsutil.CreateFile([]byte{}, 0644, "reply")
+			return nil
+		},
+		OnWrite: func(h *Handle, b []byte, off int64) (int, error) {
+			h.Content.Truncate(0)
+			h.Content.Write([]byte(strings.ToUpper(string(b))))
+			return len(b), nil
+		},
+		OnClose: func(h *Handle) error {
+			h.Aux = "closed"
+			return nil
+		},
+	}
+}
+
+func TestHandles(t *testing.T) {
+	f := CreateFile([]byte{}, 0644, "test", echo())
+	h1, _ := f.Open(os.O_RDWR)
+	h2, _ := f.Open(os.O_RDWR)
+	h1.Write([]byte("chris"))
+	h2.Write([]byte("bliss"))
+	for _, test := range []struct {
+		h      *Handle
+		expect string
+	}{{h1, "CHRIS"}, {h2, "BLISS"}} {
+		b := make([]byte, 5)
+		if _, err := test.h.ReadAt(b, 0); err != nil && err != io.EOF {
+			t.Fatal("error reading reply:", err)
+		}
+		if string(b) != test.expect {
+			t.Fatalf("expected %s got %s", test.expect, b)
+		}
+		test.h.Close()
+		if test.h.Aux != "closed" {
+			t.Fatal("OnClose was not called")
+		}
+	}
+	if f.Content.Size() != 0 {
+		t.Fatal("replies leaked into the shared content")
+	}
+}
+
+func TestNoBlock(t *testing.T) {
+	block := make(chan struct{})
+	f := CreateFile(m1, 0644, "test", Handler{
+		OnRead: func(h *Handle, b []byte, off int64) (int, error) {
+			if h.Aux != nil {
+				<-block
+			}
+			return h.Content.ReadAt(b, off)
+		},
+	})
+	h1, _ := f.Open(os.O_RDONLY)
+	h1.Aux = true
+	h2, _ := f.Open(os.O_RDONLY)
+	go h1.Read(make([]byte, 1))
+	done := make(chan error)
+	go func() {
+		_, err := h2.Read(make([]byte, 1))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal("error reading second handle:", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocked handle held up another handle")
+	}
+	close(block)
+}
+
+func TestQuery(t *testing.T) {
+	f := CreateQuery(0644, "query", func(reply ffs.Writer, query []byte) error {
+		if len(query) == 0 {
+			return io.ErrUnexpectedEOF
+		}
+		_, err := reply.Write([]byte(strings.ToUpper(string(query))))
+		return err
+	})
+	h1, _ := f.Open(os.O_RDWR)
+	h2, _ := f.Open(os.O_RDWR)
+	h1.Write([]byte("chris"))
+	if _, err := h2.Write([]byte{}); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected %v got %v", io.ErrUnexpectedEOF, err)
+	}
+	h2.Write([]byte("bliss"))
+	h1.Seek(0, io.SeekStart)
+	if b, _ := ioutil.ReadAll(h1); string(b) != "CHRIS" {
+		t.Fatalf("expected %s got %s", "CHRIS", b)
+	}
+	h2.Seek(0, io.SeekStart)
+	if b, _ := ioutil.ReadAll(h2); string(b) != "BLISS" {
+		t.Fatalf("expected %s got %s", "BLISS", b)
+	}
+}
@@ -0,0 +1,38 @@
+package synthfile
+
+import (
+	"os"
+
+	"github.com/majiru/ffs"
+	"github.com/majiru/ffs/pkg/fsutil"
+)
+
+//QueryFunc answers query by writing the result to reply.
+type QueryFunc func(reply ffs.Writer, query []byte) error
+
+//CreateQuery creates a File where each write is a query answered by fn.
+//The result is what the writing handle reads from then on,
+//it is never seen by other handles. As with Plan 9 files,
+//the reply is read from offset 0 regardless of where the query was written.
+//A failed query returns its error from the write and keeps the last result.
+func CreateQuery(mode os.FileMode, name string, fn QueryFunc) *File {
+	return CreateFile([]byte{}, mode, name, Handler{
+		OnOpen: func(h *Handle) error {
+			h.Content = fsutil.CreateFile([]byte{}, 0444, name)
+			return nil
+		},
+		OnWrite: func(h *Handle, b []byte, off int64) (int, error) {
+			reply := fsutil.CreateFile([]byte{}, 0444, name)
+			if err := fn(reply, b); err != nil {
+				return 0, err
+			}
+			h.Content = reply
+			return len(b), nil
+		},
+		//Writers such as HTTP PUT truncate after the query,
+		//the reply is not theirs to cut short.
+		OnTruncate: func(h *Handle, size int64) error {
+			return nil
+		},
+	})
+}